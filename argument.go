@@ -6,10 +6,16 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/pkg/errors"
 )
 
+// Common errors.
+var (
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
 // Argument represents an OSC argument.
 // An OSC argument can have many different types, which is why
 // we choose to represent them with an interface.
@@ -58,16 +64,57 @@ func ReadArgument(tt byte, data []byte) (Argument, int64, error) {
 		return Bool(true), 0, nil
 	case TypetagFalse:
 		return Bool(false), 0, nil
+	case TypetagNil:
+		return Nil{}, 0, nil
+	case TypetagImpulse:
+		return Impulse{}, 0, nil
 	case TypetagString:
 		s, idx := ReadString(data)
 		return String(s), idx, nil
 	case TypetagBlob:
 		return ReadBlobFrom(data)
+	case TypetagTimetag:
+		tt, err := ReadTimetag(data)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "read timetag argument")
+		}
+		return tt, TimetagSize, nil
 	default:
 		return nil, 0, errors.Wrapf(ErrInvalidTypeTag, "typetag %q", string(tt))
 	}
 }
 
+// toArgument converts a Go value to an OSC argument.
+// Arguments are returned as they are.
+// If v has a type that has no OSC equivalent ErrUnsupportedType is returned.
+func toArgument(v interface{}) (Argument, error) {
+	switch x := v.(type) {
+	case nil:
+		return Nil{}, nil
+	case Argument:
+		return x, nil
+	case int32:
+		return Int(x), nil
+	case int:
+		if x < math.MinInt32 || x > math.MaxInt32 {
+			return nil, errors.Wrapf(ErrUnsupportedType, "%d overflows int32", x)
+		}
+		return Int(x), nil
+	case float32:
+		return Float(x), nil
+	case float64:
+		return Float(x), nil
+	case string:
+		return String(x), nil
+	case bool:
+		return Bool(x), nil
+	case []byte:
+		return Blob(x), nil
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
 // Int represents a 32-bit integer.
 type Int int32
 
@@ -285,8 +332,18 @@ func ReadBlobFrom(data []byte) (Argument, int64, error) {
 	if err := binary.Read(bytes.NewReader(data), byteOrder, &length); err != nil {
 		return nil, 0, errors.Wrap(err, "read blob argument")
 	}
+	if length < 0 {
+		return nil, 0, errors.Wrapf(ErrParse, "read blob argument: negative size %d", length)
+	}
+	if int(length) > len(data)-4 {
+		return nil, 0, errors.Wrapf(ErrParse, "read blob argument: size %d exceeds remaining %d bytes", length, len(data)-4)
+	}
 	b, bl := ReadBlob(length, data[4:])
-	return Blob(b), bl + 4, nil
+	if int(length) < len(b) {
+		b = b[:length] // Drop the padding.
+	}
+	// Copy the blob so that it does not alias data, which may be a read buffer that gets reused.
+	return Blob(append([]byte{}, b...)), bl + 4, nil
 }
 
 // Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
@@ -336,5 +393,87 @@ func (b Blob) WriteTo(w io.Writer) (int64, error) {
 	return int64(written), err
 }
 
+// Nil is the OSC nil argument.
+// It has a type tag but no data.
+type Nil struct{}
+
+// Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
+func (n Nil) Bytes() []byte {
+	return []byte{}
+}
+
+// Equal returns true if the argument equals the other one, false otherwise.
+func (n Nil) Equal(other Argument) bool {
+	return other.Typetag() == TypetagNil
+}
+
+// ReadInt32 reads a 32-bit integer from the arg.
+func (n Nil) ReadInt32() (int32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadFloat32 reads a 32-bit float from the arg.
+func (n Nil) ReadFloat32() (float32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadBool bool reads a boolean from the arg.
+func (n Nil) ReadBool() (bool, error) { return false, ErrInvalidTypeTag }
+
+// ReadString string reads a string from the arg.
+func (n Nil) ReadString() (string, error) { return "", ErrInvalidTypeTag }
+
+// ReadBlob reads a slice of bytes from the arg.
+func (n Nil) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
+
+// String converts the arg to a string.
+func (n Nil) String() string { return "Nil" }
+
+// Typetag returns the argument's type tag.
+func (n Nil) Typetag() byte { return TypetagNil }
+
+// WriteTo writes the arg to an io.Writer.
+func (n Nil) WriteTo(w io.Writer) (int64, error) {
+	written, err := fmt.Fprint(w, "nil")
+	return int64(written), err
+}
+
+// Impulse is the OSC impulse argument, also known as bang.
+// It has a type tag but no data.
+type Impulse struct{}
+
+// Bytes converts the arg to a byte slice suitable for adding to the binary representation of an OSC message.
+func (i Impulse) Bytes() []byte {
+	return []byte{}
+}
+
+// Equal returns true if the argument equals the other one, false otherwise.
+func (i Impulse) Equal(other Argument) bool {
+	return other.Typetag() == TypetagImpulse
+}
+
+// ReadInt32 reads a 32-bit integer from the arg.
+func (i Impulse) ReadInt32() (int32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadFloat32 reads a 32-bit float from the arg.
+func (i Impulse) ReadFloat32() (float32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadBool bool reads a boolean from the arg.
+func (i Impulse) ReadBool() (bool, error) { return false, ErrInvalidTypeTag }
+
+// ReadString string reads a string from the arg.
+func (i Impulse) ReadString() (string, error) { return "", ErrInvalidTypeTag }
+
+// ReadBlob reads a slice of bytes from the arg.
+func (i Impulse) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
+
+// String converts the arg to a string.
+func (i Impulse) String() string { return "Impulse" }
+
+// Typetag returns the argument's type tag.
+func (i Impulse) Typetag() byte { return TypetagImpulse }
+
+// WriteTo writes the arg to an io.Writer.
+func (i Impulse) WriteTo(w io.Writer) (int64, error) {
+	written, err := fmt.Fprint(w, "impulse")
+	return int64(written), err
+}
+
 // Arguments is a slice of Argument.
 type Arguments []Argument
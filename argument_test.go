@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -417,12 +418,16 @@ func TestReadArgument(t *testing.T) {
 		{
 			// Length followed by blob
 			Input:    Input{tt: TypetagBlob, data: []byte{0, 0, 0, 5, 'a', 'b', 'c', 'd', 'e'}},
-			Expected: Output{Argument: Blob([]byte{'a', 'b', 'c', 'd', 'e', 0, 0, 0}), Consumed: 12},
+			Expected: Output{Argument: Blob([]byte{'a', 'b', 'c', 'd', 'e'}), Consumed: 12},
 		},
 		{
 			Input:    Input{tt: TypetagBlob, data: []byte{}},
 			Expected: Output{Err: errors.New("read blob argument: EOF")},
 		},
+		{
+			Input:    Input{tt: TypetagBlob, data: []byte{0xFF, 0xFF, 0xFF, 0xFC}},
+			Expected: Output{Err: errors.New("read blob argument: negative size -4: error parsing message")},
+		},
 		{
 			Input:    Input{tt: 'Q'},
 			Expected: Output{Err: errors.Wrap(ErrInvalidTypeTag, `typetag "Q"`)},
@@ -473,13 +478,17 @@ func TestReadArguments(t *testing.T) {
 			Expected: Output{Arguments: []Argument{Int(1)}},
 		},
 		{
-			Input: Input{Typetags: []byte{TypetagBlob}, Data: []byte{0, 0, 1, 1, 4, 5, 6, 7}},
+			Input: Input{Typetags: []byte{TypetagBlob}, Data: []byte{0, 0, 0, 4, 4, 5, 6, 7}},
 			Expected: Output{
 				Arguments: []Argument{
 					Blob([]byte{4, 5, 6, 7}),
 				},
 			},
 		},
+		{
+			Input:    Input{Typetags: []byte{TypetagBlob}, Data: []byte{0, 0, 1, 1, 4, 5, 6, 7}},
+			Expected: Output{Err: errors.New("read argument 0: read blob argument: size 257 exceeds remaining 4 bytes: error parsing message")},
+		},
 	} {
 		args, err := ReadArguments(testcase.Input.Typetags, testcase.Input.Data)
 
@@ -502,3 +511,55 @@ func TestReadArguments(t *testing.T) {
 		}
 	}
 }
+
+func TestNilAndImpulse(t *testing.T) {
+	for _, arg := range []Argument{Nil{}, Impulse{}} {
+		if expected, got := 0, len(arg.Bytes()); expected != got {
+			t.Fatalf("expected %d bytes, got %d", expected, got)
+		}
+		if _, err := arg.ReadInt32(); err != ErrInvalidTypeTag {
+			t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+		}
+		if _, err := arg.ReadString(); err != ErrInvalidTypeTag {
+			t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+		}
+		if _, err := arg.WriteTo(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !(Nil{}).Equal(Nil{}) {
+		t.Fatal("expected Nil to equal Nil")
+	}
+	if (Nil{}).Equal(Impulse{}) {
+		t.Fatal("expected Nil to not equal Impulse")
+	}
+	if expected, got := TypetagNil, (Nil{}).Typetag(); expected != got {
+		t.Fatalf("expected %c, got %c", expected, got)
+	}
+	if expected, got := TypetagImpulse, (Impulse{}).Typetag(); expected != got {
+		t.Fatalf("expected %c, got %c", expected, got)
+	}
+}
+
+func TestTimetagArgument(t *testing.T) {
+	var (
+		tt  = FromTime(time.Date(2017, time.March, 4, 12, 30, 15, 0, time.UTC))
+		msg = Message{Address: "/foo", Arguments: Arguments{Int(1), tt, String("bar")}}
+	)
+	if expected, got := ",its", string(bytes.TrimRight(msg.Typetags(), "\x00")); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	got, err := ParseMessage(msg.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", msg, got)
+	}
+	if expected, got := "2017-03-04T12:30:15Z", got.Arguments[1].String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if _, err := ParseMessage(msg.Bytes()[:20], nil); errors.Cause(err) != ErrParse {
+		t.Fatalf("expected ErrParse, got %+v", err)
+	}
+}
@@ -15,10 +15,14 @@ const (
 
 // Common errors.
 var (
-	ErrEarlyTimetag = errors.New("enclosing bundle's timetag was later than the nested bundle's")
-	ErrEndOfPackets = errors.New("end of packets")
+	ErrEarlyTimetag   = errors.New("enclosing bundle's timetag was later than the nested bundle's")
+	ErrEndOfPackets   = errors.New("end of packets")
+	ErrPacketTooLarge = errors.New("packet too large")
 )
 
+// bundleHeaderSize is the size of the bundle tag and the timetag that start every bundle.
+const bundleHeaderSize = 16
+
 // Bundle is an OSC bundle.
 // An OSC Bundle consists of the OSC-string "#bundle" followed by an OSC Time Tag,
 // followed by zero or more bundle elements. The OSC-timetag is a 64-bit fixed
@@ -29,6 +33,12 @@ type Bundle struct {
 	Sender  net.Addr
 }
 
+// NewBundle creates a bundle with the provided timetag and packets.
+// Packets can be messages or nested bundles.
+func NewBundle(t Timetag, packets ...Packet) Bundle {
+	return Bundle{Timetag: t, Packets: packets}
+}
+
 // ParseBundle parses a bundle from a byte slice.
 func ParseBundle(data []byte, sender net.Addr) (Bundle, error) {
 	return parseBundle(data, sender, -1)
@@ -38,7 +48,7 @@ func ParseBundle(data []byte, sender net.Addr) (Bundle, error) {
 // It will stop after reading limit bytes.
 // If you wish to have it consume as many bytes as possible, pass -1 as the limit.
 func parseBundle(data []byte, sender net.Addr, limit int32) (Bundle, error) {
-	b := Bundle{}
+	b := Bundle{Sender: sender}
 
 	// If 0 <= limit < 16 this is an error.
 	// We have to be able to read at least the bundle tag and a timetag.
@@ -84,11 +94,37 @@ func (b Bundle) Bytes() []byte {
 			bs     = p.Bytes()
 			length = Int(int32(len(bs)))
 		)
-		bss = append(bss, length.Bytes(), p.Bytes())
+		bss = append(bss, length.Bytes(), bs)
 	}
 	return bytes.Join(bss, []byte{})
 }
 
+// Split splits the bundle into bundles with the same timetag whose packets are the packets of b,
+// in order, so that none of the bundles is larger than maxSize bytes, e.g. the MTU of a network.
+// Nested bundles are not split. If one of the packets does not fit into a bundle
+// on its own an error whose cause is ErrPacketTooLarge is returned.
+func (b Bundle) Split(maxSize int) ([]Bundle, error) {
+	var (
+		bundles = []Bundle{}
+		curr    = Bundle{Timetag: b.Timetag}
+		size    = bundleHeaderSize
+	)
+	for i, p := range b.Packets {
+		n := 4 + len(p.Bytes()) // Elements are prefixed with their size.
+
+		if bundleHeaderSize+n > maxSize {
+			return nil, errors.Wrapf(ErrPacketTooLarge, "packet %d needs a %d byte bundle, the maximum is %d", i, bundleHeaderSize+n, maxSize)
+		}
+		if size+n > maxSize {
+			bundles = append(bundles, curr)
+			curr, size = Bundle{Timetag: b.Timetag}, bundleHeaderSize
+		}
+		curr.Packets = append(curr.Packets, p)
+		size += n
+	}
+	return append(bundles, curr), nil
+}
+
 // Equal returns true if one bundle equals another, and false otherwise.
 func (b Bundle) Equal(other Packet) bool {
 	b2, ok := other.(Bundle)
@@ -144,8 +180,10 @@ func readPackets(data []byte, sender net.Addr, limit int32) ([]Packet, error) {
 		if l+4 == int32(len(data)) {
 			break
 		}
-		if (limit >= 0) && (limit <= l+4) {
-			break
+		if limit >= 0 {
+			if limit -= l + 4; limit <= 0 {
+				break
+			}
 		}
 		data = data[l+4:]
 	}
@@ -167,12 +205,16 @@ func readPacket(data []byte, sender net.Addr) (Packet, int32, error) {
 	if l == int32(0) {
 		return nil, 0, ErrEndOfPackets
 	}
+	if l < 0 {
+		return nil, 0, errors.Wrapf(ErrParse, "negative packet length %d", l)
+	}
 
 	data = data[4:]
 
 	if int32(len(data)) < l {
 		return nil, 0, errors.Errorf("packet length %d is greater than data length %d", l, len(data))
 	}
+	data = data[:l] // A nested bundle must not read the packets that follow it.
 
 	switch data[0] {
 	case MessageChar:
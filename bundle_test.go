@@ -2,7 +2,9 @@ package osc
 
 import (
 	"bytes"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -390,3 +392,119 @@ func TestParseBundleLimit(t *testing.T) {
 		t.Fatalf("expected %s, got %s", expected, got)
 	}
 }
+
+func TestParseBundleSender(t *testing.T) {
+	sender := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 57120}
+
+	data := NewBundle(Immediately,
+		Message{Address: "/foo"},
+		NewBundle(Immediately, Message{Address: "/bar"}),
+	).Bytes()
+
+	b, err := ParseBundle(data, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range []Packet{b, b.Packets[0], b.Packets[1], b.Packets[1].(Bundle).Packets[0]} {
+		if got := PacketSender(p); got != sender {
+			t.Fatalf("(packet %d) expected sender %s, got %v", i, sender, got)
+		}
+	}
+	if got := PacketSender(badPacket{}); got != nil {
+		t.Fatalf("expected nil, got %s", got)
+	}
+}
+func TestNewBundleRoundTrip(t *testing.T) {
+	tt := FromTime(time.Now())
+
+	for i, b := range []Bundle{
+		NewBundle(tt),
+		NewBundle(tt, Message{Address: "/foo", Arguments: Arguments{Int(1), String("bar")}}),
+		NewBundle(tt,
+			Message{Address: "/foo", Arguments: Arguments{Float(2)}},
+			NewBundle(tt,
+				Message{Address: "/bar", Arguments: Arguments{Blob([]byte{1, 2, 3})}},
+				NewBundle(tt+1, Message{Address: "/baz"}),
+			),
+			Message{Address: "/qux"},
+		),
+	} {
+		data := b.Bytes()
+		if expected, got := BundleTag+"\x00", string(data[:8]); expected != got {
+			t.Fatalf("(testcase %d) expected header %q, got %q", i, expected, got)
+		}
+		got, err := ParseBundle(data, nil)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if !b.Equal(got) {
+			t.Fatalf("(testcase %d) expected %+v, got %+v", i, b, got)
+		}
+	}
+}
+
+func TestBundleSplit(t *testing.T) {
+	var (
+		msg = Message{Address: "/foo", Arguments: Arguments{Int(1)}} // 16 bytes, 20 as an element.
+		b   = NewBundle(FromTime(time.Unix(1, 0)), msg, msg, msg, msg, msg)
+	)
+	bundles, err := b.Split(bundleHeaderSize + 2*20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Bundle{
+		NewBundle(b.Timetag, msg, msg),
+		NewBundle(b.Timetag, msg, msg),
+		NewBundle(b.Timetag, msg),
+	}
+	if len(expected) != len(bundles) {
+		t.Fatalf("expected %d bundles, got %d", len(expected), len(bundles))
+	}
+	for i, got := range bundles {
+		if !expected[i].Equal(got) {
+			t.Fatalf("(bundle %d) expected %+v, got %+v", i, expected[i], got)
+		}
+		if size := len(got.Bytes()); size > bundleHeaderSize+2*20 {
+			t.Fatalf("(bundle %d) expected at most %d bytes, got %d", i, bundleHeaderSize+2*20, size)
+		}
+	}
+	if _, err := b.Split(bundleHeaderSize + 19); errors.Cause(err) != ErrPacketTooLarge {
+		t.Fatalf("expected ErrPacketTooLarge, got %v", err)
+	}
+}
+
+func FuzzParseBundle(f *testing.F) {
+	msg := Message{Address: "/foo", Arguments: Arguments{Int(1), String("bar")}}
+	for _, b := range []Bundle{
+		NewBundle(Immediately),
+		NewBundle(Immediately, msg),
+		NewBundle(Immediately, msg, NewBundle(Immediately, msg)),
+	} {
+		f.Add(b.Bytes())
+	}
+	for _, data := range [][]byte{
+		{},
+		[]byte("#bundle"),
+		append(ToBytes(BundleTag), 0, 0, 0, 0, 0, 0, 0, 1),
+		append(ToBytes(BundleTag), 0, 0, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff),
+		append(ToBytes(BundleTag), 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 8, '/'),
+	} {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b, err := ParseBundle(data, nil)
+		if err != nil {
+			return
+		}
+		if _, err := ParseBundle(b.Bytes(), nil); err != nil {
+			t.Fatalf("parse %q: %s", b.Bytes(), err)
+		}
+	})
+}
+
+func TestParseBundleNegativeLength(t *testing.T) {
+	data := append(ToBytes(BundleTag), 0, 0, 0, 0, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff)
+	if _, err := ParseBundle(data, nil); errors.Cause(err) != ErrParse {
+		t.Fatalf("expected ErrParse, got %v", err)
+	}
+}
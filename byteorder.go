@@ -0,0 +1,55 @@
+package osc
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// DetectByteOrder guesses the byte order that the numeric arguments of a message were encoded with.
+// OSC requires big-endian numbers, but some nonconformant peers send little-endian ones,
+// which parse as garbage: tiny or huge floats and huge ints.
+//
+// This is a best-effort heuristic. Each int and float argument is read both ways,
+// and binary.LittleEndian is returned if more of the arguments have plausible values when
+// their bytes are swapped. Otherwise (including for messages with no numeric arguments)
+// binary.BigEndian is returned.
+func DetectByteOrder(msg *Message) binary.ByteOrder {
+	var big, little int
+
+	for _, arg := range msg.Arguments {
+		switch x := arg.(type) {
+		case Int:
+			big += plausibleInt(int32(x))
+			little += plausibleInt(int32(bits.ReverseBytes32(uint32(x))))
+		case Float:
+			b := math.Float32bits(float32(x))
+			big += plausibleFloat(float32(x))
+			little += plausibleFloat(math.Float32frombits(bits.ReverseBytes32(b)))
+		}
+	}
+	if little > big {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// plausibleInt returns 1 if i looks like an int that someone would send, and 0 otherwise.
+func plausibleInt(i int32) int {
+	if i > -1<<24 && i < 1<<24 {
+		return 1
+	}
+	return 0
+}
+
+// plausibleFloat returns 1 if f looks like a float that someone would send, and 0 otherwise.
+func plausibleFloat(f float32) int {
+	if f == 0 {
+		return 1
+	}
+	abs := math.Abs(float64(f))
+	if abs >= 1e-6 && abs <= 1e9 {
+		return 1
+	}
+	return 0
+}
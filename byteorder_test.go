@@ -0,0 +1,38 @@
+package osc
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"testing"
+)
+
+func TestDetectByteOrder(t *testing.T) {
+	var (
+		swapInt   = func(i int32) Int { return Int(int32(bits.ReverseBytes32(uint32(i)))) }
+		swapFloat = func(f float32) Float {
+			return Float(math.Float32frombits(bits.ReverseBytes32(math.Float32bits(f))))
+		}
+	)
+	for i, testcase := range []struct {
+		Message  Message
+		Expected binary.ByteOrder
+	}{
+		{
+			Message:  Message{Address: "/synth", Arguments: Arguments{Int(1), Float(440), Float(0.5)}},
+			Expected: binary.BigEndian,
+		},
+		{
+			Message:  Message{Address: "/synth", Arguments: Arguments{swapInt(1), swapFloat(440), swapFloat(0.5)}},
+			Expected: binary.LittleEndian,
+		},
+		{
+			Message:  Message{Address: "/synth", Arguments: Arguments{String("foo")}},
+			Expected: binary.BigEndian,
+		},
+	} {
+		if expected, got := testcase.Expected, DetectByteOrder(&testcase.Message); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
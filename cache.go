@@ -0,0 +1,109 @@
+package osc
+
+import (
+	"context"
+	"sync"
+)
+
+// maxCachedAddresses limits the number of addresses a Cache remembers.
+// Addresses come from the network, so if the wrapped dispatcher can not tell
+// which messages matched a handler the cache must not grow without bound.
+const maxCachedAddresses = 4096
+
+// Cache is a dispatcher that remembers the last message it has seen for each address.
+// Every packet is passed on to the wrapped Dispatcher.
+// This makes it easy to answer queries for the last known value of a parameter.
+//
+// If the wrapped dispatcher is a PatternMatching, FuncMatching, OrderedMatching or SpecificMatching,
+// only messages that matched one of its handlers are cached, and they are cached as they are invoked.
+// Other dispatchers get every message, which is cached before it is dispatched,
+// but at most maxCachedAddresses addresses are remembered.
+type Cache struct {
+	Dispatcher
+
+	mu   sync.RWMutex
+	last map[string]Message
+}
+
+// NewCache creates a Cache that wraps the provided dispatcher.
+func NewCache(dispatcher Dispatcher) *Cache {
+	return &Cache{
+		Dispatcher: dispatcher,
+		last:       map[string]Message{},
+	}
+}
+
+// Dispatch caches the messages in the bundle and dispatches it.
+func (c *Cache) Dispatch(b Bundle, exactMatch bool) error {
+	return c.DispatchContext(context.Background(), b, exactMatch)
+}
+
+// DispatchContext caches the messages in the bundle and dispatches it,
+// giving up on a bundle that is scheduled for the future when ctx is done.
+// If the wrapped dispatcher is not a ContextDispatcher ctx is ignored.
+func (c *Cache) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	if _, ok := asCountingDispatcher(c.Dispatcher); ok {
+		return dispatchContext(ctx, b, func(msg Message) error {
+			return c.Invoke(msg, exactMatch)
+		})
+	}
+	c.storeBundle(b)
+
+	if cd, ok := c.Dispatcher.(ContextDispatcher); ok {
+		return cd.DispatchContext(ctx, b, exactMatch)
+	}
+	return c.Dispatcher.Dispatch(b, exactMatch)
+}
+
+// Invoke caches the message and invokes it.
+func (c *Cache) Invoke(msg Message, exactMatch bool) error {
+	cd, ok := asCountingDispatcher(c.Dispatcher)
+	if !ok {
+		c.store(msg.clone())
+		return c.Dispatcher.Invoke(msg, exactMatch)
+	}
+	orig := msg.clone() // The handlers may modify the message.
+
+	matched, err := cd.invokeCounted(msg, exactMatch)
+	if matched > 0 {
+		c.store(orig)
+	}
+	return err
+}
+
+// Last returns a copy of the last message that was seen for the provided address.
+// The second return value is false if no message has been seen for the address.
+func (c *Cache) Last(address string) (*Message, bool) {
+	c.mu.RLock()
+	msg, ok := c.last[address]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	clone := msg.clone()
+	return &clone, true
+}
+
+// store caches a message that the caller does not share with anyone.
+// New addresses are not cached once the cache has maxCachedAddresses of them.
+func (c *Cache) store(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.last[msg.Address]; !ok && len(c.last) >= maxCachedAddresses {
+		return
+	}
+	c.last[msg.Address] = msg
+}
+
+// storeBundle caches all the messages in a bundle, including nested bundles.
+func (c *Cache) storeBundle(b Bundle) {
+	for _, p := range b.Packets {
+		switch x := p.(type) {
+		case Message:
+			c.store(x.clone())
+		case Bundle:
+			c.storeBundle(x)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package osc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCache(t *testing.T) {
+	var (
+		invoked = 0
+		c       = NewCache(PatternMatching{
+			"/level": Method(func(msg Message) error {
+				invoked++
+				return nil
+			}),
+		})
+	)
+	if _, ok := c.Last("/level"); ok {
+		t.Fatal("expected no cached message")
+	}
+	for i := 0; i < 3; i++ {
+		if err := c.Invoke(Message{Address: "/level", Arguments: Arguments{Float(i)}}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected, got := 3, invoked; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	last, ok := c.Last("/level")
+	if !ok {
+		t.Fatal("expected a cached message")
+	}
+	if expected := (Message{Address: "/level", Arguments: Arguments{Float(2)}}); !expected.Equal(*last) {
+		t.Fatalf("expected %+v, got %+v", expected, last)
+	}
+	// Modifying the returned message does not affect the cache.
+	last.Arguments[0] = Float(10)
+
+	again, _ := c.Last("/level")
+	if expected := (Message{Address: "/level", Arguments: Arguments{Float(2)}}); !expected.Equal(*again) {
+		t.Fatalf("expected %+v, got %+v", expected, again)
+	}
+}
+
+func TestCacheDispatch(t *testing.T) {
+	nop := Method(func(msg Message) error { return nil })
+	c := NewCache(PatternMatching{"/a": nop, "/b": nop})
+
+	b := Bundle{
+		Timetag: FromTime(time.Now()),
+		Packets: []Packet{
+			Message{Address: "/a", Arguments: Arguments{Int(1)}},
+			Bundle{
+				Timetag: FromTime(time.Now()),
+				Packets: []Packet{
+					Message{Address: "/b", Arguments: Arguments{Int(2)}},
+				},
+			},
+		},
+	}
+	if err := c.Dispatch(b, false); err != nil {
+		t.Fatal(err)
+	}
+	for addr, expected := range map[string]Message{
+		"/a": {Address: "/a", Arguments: Arguments{Int(1)}},
+		"/b": {Address: "/b", Arguments: Arguments{Int(2)}},
+	} {
+		got, ok := c.Last(addr)
+		if !ok {
+			t.Fatalf("expected a cached message for %s", addr)
+		}
+		if !expected.Equal(*got) {
+			t.Fatalf("expected %+v, got %+v", expected, *got)
+		}
+	}
+}
+
+func TestCacheUnmatched(t *testing.T) {
+	c := NewCache(PatternMatching{
+		"/level": Method(func(msg Message) error { return nil }),
+	})
+	for _, addr := range []string{"/level", "/nobody/home", "/l*"} {
+		if err := c.Invoke(Message{Address: addr}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.Last("/nobody/home"); ok {
+		t.Fatal("expected the unmatched message to not be cached")
+	}
+	for _, addr := range []string{"/level", "/l*"} {
+		if _, ok := c.Last(addr); !ok {
+			t.Fatalf("expected a cached message for %s", addr)
+		}
+	}
+	// Other dispatchers get every message, but the number of addresses is limited.
+	c = NewCache(errorDispatcher{})
+	for i := 0; i < maxCachedAddresses+10; i++ {
+		_ = c.Invoke(Message{Address: fmt.Sprintf("/addr/%d", i)}, false)
+	}
+	if expected, got := maxCachedAddresses, len(c.last); expected != got {
+		t.Fatalf("expected %d cached addresses, got %d", expected, got)
+	}
+}
+
+func TestCacheDispatchContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewCache(PatternMatching{"/foo": Method(func(msg Message) error { return nil })})
+	b := NewBundle(FromTime(time.Now().Add(time.Hour)), Message{Address: "/foo"})
+
+	if err := c.DispatchContext(ctx, b, false); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, ok := c.Last("/foo"); ok {
+		t.Fatal("expected /foo not to be cached")
+	}
+}
+
+func TestCacheServeAbandonsScheduledBundles(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := startedDispatcher{
+		PatternMatching: PatternMatching{"/foo": Method(func(msg Message) error { return nil })},
+		started:         make(chan struct{}),
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Serve(1, NewCache(d))
+	}()
+	client, err := DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(NewBundle(FromTime(time.Now().Add(time.Hour)), Message{Address: "/foo"})); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-d.started:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for bundle to be dispatched")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheCheckDispatcher(t *testing.T) {
+	c := NewCache(PatternMatching{
+		"/Level": Method(func(msg Message) error { return nil }),
+	})
+	if err := checkDispatcher(c, regexp.MustCompile(`^/[a-z]+$`)); errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package osc
+
+import (
+	"context"
+	"net"
+)
+
+// Client sends OSC packets to a single remote address over UDP.
+// It is the simplest way to fire off OSC without setting up a server.
+type Client struct {
+	conn *UDPConn
+}
+
+// NewClient creates a client that sends to addr, which is a "host:port" string.
+func NewClient(addr string) (*Client, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := DialUDPContext(context.Background(), "udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the client's connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Conn returns the connection the client sends on.
+func (c *Client) Conn() *UDPConn {
+	return c.conn
+}
+
+// Send sends a packet to the client's remote address.
+func (c *Client) Send(p Packet) error {
+	return c.conn.Send(p)
+}
@@ -0,0 +1,46 @@
+package osc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	msg := Message{Address: "/transport/play", Arguments: Arguments{Float(1)}}
+
+	if err := client.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	got, sender, err := server.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", msg, got)
+	}
+	if expected, got := client.Conn().LocalAddr().String(), sender.String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestNewClientBadAddress(t *testing.T) {
+	if _, err := NewClient("not an address"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"errors"
 	"net"
 	"strings"
+	"time"
 )
 
 const (
 	// bufSize is the size of read and write buffers.
 	// SuperCollider synthdef messages can easily have as much as 64K of data.
 	bufSize = 65536
+
+	// readTimeout is how long Serve blocks reading from a packet conn
+	// before it checks whether it should stop.
+	readTimeout = 100 * time.Millisecond
 )
 
 // Common errors.
@@ -32,9 +37,15 @@ type Conn interface {
 
 var invalidAddressRunes = []rune{'*', '?', ',', '[', ']', '{', '}', '#', ' '}
 
-// ValidateAddress returns an error if addr contains
-// characters that are disallowed by the OSC spec.
+// ValidateAddress returns ErrInvalidAddress if addr is not a valid
+// concrete OSC address, i.e. an address that a handler can be added for.
+// A valid address is non-empty, begins with '/', and does not contain
+// spaces or any of the characters that have a special meaning in
+// address patterns: '#', '*', ',', '?', '[', ']', '{' and '}'.
 func ValidateAddress(addr string) error {
+	if len(addr) == 0 || addr[0] != MessageChar {
+		return ErrInvalidAddress
+	}
 	for _, chr := range invalidAddressRunes {
 		if strings.ContainsRune(addr, chr) {
 			return ErrInvalidAddress
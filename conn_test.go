@@ -19,10 +19,35 @@ func TestUDPConn(t *testing.T) {
 }
 
 func TestValidateAddress(t *testing.T) {
-	if err := ValidateAddress("/foo"); err != nil {
-		t.Fatal(err)
+	for _, addr := range []string{
+		"/foo",
+		"/foo/bar",
+		"/mixer/ch1/gain",
+		"/foo.bar/baz-qux_1",
+		"/",
+	} {
+		if err := ValidateAddress(addr); err != nil {
+			t.Fatalf("(address %q) %s", addr, err)
+		}
 	}
-	if err := ValidateAddress("/foo@^#&*$^*%)()#($*@"); err == nil {
-		t.Fatal("expected error, got nil")
+	for _, addr := range []string{
+		"",
+		"foo",
+		"foo/bar",
+		"/foo bar",
+		"/foo#bar",
+		"/foo*bar",
+		"/address*/test",
+		"/foo,bar",
+		"/foo?bar",
+		"/foo[bar",
+		"/foo]bar",
+		"/foo{bar",
+		"/foo}bar",
+		"/foo@^#&*$^*%)()#($*@",
+	} {
+		if err := ValidateAddress(addr); err != ErrInvalidAddress {
+			t.Fatalf("(address %q) expected ErrInvalidAddress, got %+v", addr, err)
+		}
 	}
 }
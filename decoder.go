@@ -0,0 +1,150 @@
+package osc
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Decoder decodes OSC data directly from a byte slice, without copying it.
+// Each Decode method decodes the value at the current offset and
+// advances the offset past the value and its padding.
+// If decoding fails the offset does not change.
+//
+// The byte slices returned by DecodeStringBytes and DecodeBlob point into the decoder's data,
+// so they are only valid as long as the data is.
+//
+// A message can be decoded by decoding its address and type tags and then
+// decoding its arguments according to the type tags:
+//
+//	d := NewDecoder(data)
+//	addr, err := d.DecodeStringBytes()
+//	typetags, err := d.DecodeStringBytes() // Includes the leading ','
+type Decoder struct {
+	data []byte
+	off  int
+}
+
+// NewDecoder creates a decoder positioned at the start of data.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Len returns the number of bytes that have not been decoded.
+func (d *Decoder) Len() int {
+	return len(d.data) - d.off
+}
+
+// Offset returns the offset of the next byte to decode.
+func (d *Decoder) Offset() int {
+	return d.off
+}
+
+// Reset makes the decoder decode data from the start.
+func (d *Decoder) Reset(data []byte) {
+	d.data, d.off = data, 0
+}
+
+// DecodeInt32 decodes a 32-bit integer.
+func (d *Decoder) DecodeInt32() (int32, error) {
+	u, err := d.uint32("int32")
+	return int32(u), err
+}
+
+// DecodeFloat32 decodes a 32-bit float.
+func (d *Decoder) DecodeFloat32() (float32, error) {
+	u, err := d.uint32("float32")
+	return math.Float32frombits(u), err
+}
+
+// DecodeTimetag decodes a timetag.
+func (d *Decoder) DecodeTimetag() (Timetag, error) {
+	if d.Len() < TimetagSize {
+		return 0, d.short("timetag", TimetagSize)
+	}
+	tt := Timetag(byteOrder.Uint64(d.data[d.off:]))
+	d.off += TimetagSize
+	return tt, nil
+}
+
+// DecodeString decodes an OSC-string.
+// It allocates the returned string, see DecodeStringBytes.
+func (d *Decoder) DecodeString() (string, error) {
+	b, err := d.DecodeStringBytes()
+	return string(b), err
+}
+
+// DecodeStringBytes decodes an OSC-string without allocating.
+// The returned slice does not include the null terminator or the padding.
+func (d *Decoder) DecodeStringBytes() ([]byte, error) {
+	rest := d.data[d.off:]
+
+	nullidx := bytes.IndexByte(rest, 0)
+	if nullidx == -1 {
+		return nil, errors.Wrapf(ErrParse, "decode string at offset %d: unterminated string", d.off)
+	}
+	l := (nullidx + 4) &^ 3
+	if l > len(rest) {
+		return nil, errors.Wrapf(ErrParse, "decode string at offset %d: string is missing padding", d.off)
+	}
+	d.off += l
+	return rest[:nullidx], nil
+}
+
+// DecodeBlob decodes a blob without allocating.
+// The returned slice does not include the padding.
+func (d *Decoder) DecodeBlob() ([]byte, error) {
+	if d.Len() < 4 {
+		return nil, d.short("blob size", 4)
+	}
+	size := int(int32(byteOrder.Uint32(d.data[d.off:])))
+	if size < 0 {
+		return nil, errors.Wrapf(ErrParse, "decode blob at offset %d: negative size %d", d.off, size)
+	}
+	padded := (size + 3) &^ 3
+	if d.Len()-4 < padded {
+		return nil, errors.Wrapf(ErrParse, "decode blob at offset %d: size %d needs %d bytes with padding, %d remaining", d.off, size, padded, d.Len()-4)
+	}
+	start := d.off + 4
+	d.off = start + padded
+	return d.data[start : start+size], nil
+}
+
+// Skip advances the decoder past an argument with the provided type tag, without decoding it.
+// Strings and blobs are skipped along with their padding, so the decoder stays aligned.
+// Arguments that have no data, like TypetagTrue and TypetagNil, are skipped without
+// moving the offset. An unknown type tag returns an error whose cause is ErrInvalidTypeTag.
+func (d *Decoder) Skip(typetag byte) error {
+	var err error
+
+	switch typetag {
+	case TypetagInt, TypetagFloat:
+		_, err = d.uint32("argument")
+	case TypetagTimetag:
+		_, err = d.DecodeTimetag()
+	case TypetagString:
+		_, err = d.DecodeStringBytes()
+	case TypetagBlob:
+		_, err = d.DecodeBlob()
+	case TypetagFalse, TypetagTrue, TypetagNil, TypetagImpulse:
+	default:
+		err = errors.Wrapf(ErrInvalidTypeTag, "skip argument at offset %d: typetag %q", d.off, string(typetag))
+	}
+	return err
+}
+
+// uint32 decodes 4 bytes.
+func (d *Decoder) uint32(what string) (uint32, error) {
+	if d.Len() < 4 {
+		return 0, d.short(what, 4)
+	}
+	u := byteOrder.Uint32(d.data[d.off:])
+	d.off += 4
+	return u, nil
+}
+
+// short returns the error for a value that needs more bytes than there are left.
+func (d *Decoder) short(what string, n int) error {
+	return errors.Wrapf(ErrParse, "decode %s at offset %d: need %d bytes, %d remaining", what, d.off, n, d.Len())
+}
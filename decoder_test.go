@@ -0,0 +1,262 @@
+package osc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecoder(t *testing.T) {
+	msg := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Int(-2), Float(1.5), String("bar"), Blob([]byte{1, 2, 3, 4, 5}), Int(7)},
+	}
+	d := NewDecoder(msg.Bytes())
+
+	addr, err := d.DecodeStringBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/foo", string(addr); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	typetags, err := d.DecodeString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := ",ifsbi", typetags; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	i, err := d.DecodeInt32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int32(-2), i; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	f, err := d.DecodeFloat32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := float32(1.5), f; expected != got {
+		t.Fatalf("expected %f, got %f", expected, got)
+	}
+	s, err := d.DecodeString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "bar", s; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	b, err := d.DecodeBlob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []byte{1, 2, 3, 4, 5}, b; !bytes.Equal(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if i, err = d.DecodeInt32(); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int32(7), i; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if expected, got := 0, d.Len(); expected != got {
+		t.Fatalf("expected %d bytes left, got %d", expected, got)
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	for i, testcase := range []struct {
+		Data     []byte
+		Decode   func(d *Decoder) error
+		Expected string
+	}{
+		{
+			Data:     []byte{0, 0, 1},
+			Decode:   func(d *Decoder) error { _, err := d.DecodeInt32(); return err },
+			Expected: "decode int32 at offset 0: need 4 bytes, 3 remaining: error parsing message",
+		},
+		{
+			Data:     []byte{'a', 'b'},
+			Decode:   func(d *Decoder) error { _, err := d.DecodeString(); return err },
+			Expected: "decode string at offset 0: unterminated string: error parsing message",
+		},
+		{
+			Data:     []byte{'a', 'b', 'c', 'd', 0},
+			Decode:   func(d *Decoder) error { _, err := d.DecodeString(); return err },
+			Expected: "decode string at offset 0: string is missing padding: error parsing message",
+		},
+		{
+			Data:     []byte{0, 0, 0, 5, 1, 2, 3, 4, 5},
+			Decode:   func(d *Decoder) error { _, err := d.DecodeBlob(); return err },
+			Expected: "decode blob at offset 0: size 5 needs 8 bytes with padding, 5 remaining: error parsing message",
+		},
+		{
+			Data:     []byte{0, 0, 0, 0, 0, 0, 0},
+			Decode:   func(d *Decoder) error { _, err := d.DecodeTimetag(); return err },
+			Expected: "decode timetag at offset 0: need 8 bytes, 7 remaining: error parsing message",
+		},
+	} {
+		d := NewDecoder(testcase.Data)
+		err := testcase.Decode(d)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if errors.Cause(err) != ErrParse {
+			t.Fatalf("(testcase %d) expected ErrParse, got %+v", i, err)
+		}
+		if expected, got := testcase.Expected, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if expected, got := 0, d.Offset(); expected != got {
+			t.Fatalf("(testcase %d) expected offset %d, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestDecoderSkip(t *testing.T) {
+	data := Message{
+		Address:   "/foo",
+		Arguments: Arguments{String("hello"), Blob{1, 2, 3, 4, 5}, Bool(true), Int(7)},
+	}.Bytes()
+
+	d := NewDecoder(data)
+	if _, err := d.DecodeStringBytes(); err != nil {
+		t.Fatal(err)
+	}
+	typetags, err := d.DecodeStringBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range typetags[1:4] {
+		if err := d.Skip(tt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i, err := d.DecodeInt32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int32(7), i; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if expected, got := 0, d.Len(); expected != got {
+		t.Fatalf("expected %d bytes left, got %d", expected, got)
+	}
+	if err := d.Skip('Q'); errors.Cause(err) != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	if err := d.Skip(TypetagInt); errors.Cause(err) != ErrParse {
+		t.Fatalf("expected ErrParse, got %+v", err)
+	}
+}
+
+var benchmarkMessage = Message{
+	Address:   "/synth/params",
+	Arguments: Arguments{Int(1), Float(440), String("sine"), Blob([]byte{1, 2, 3, 4, 5, 6, 7, 8})},
+}.Bytes()
+
+func BenchmarkDecoder(b *testing.B) {
+	b.ReportAllocs()
+
+	d := NewDecoder(nil)
+	for i := 0; i < b.N; i++ {
+		d.Reset(benchmarkMessage)
+		if _, err := d.DecodeStringBytes(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := d.DecodeStringBytes(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := d.DecodeInt32(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := d.DecodeFloat32(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := d.DecodeStringBytes(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := d.DecodeBlob(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageReader(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		msg, err := ParseMessage(benchmarkMessage, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		r := msg.Reader()
+		if _, err := r.ReadInt32(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := r.ReadFloat32(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := r.ReadString(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := r.ReadBlob(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoderCrossCheck(t *testing.T) {
+	// Every number of arguments from 0 to 8 puts the type tag string's
+	// null terminator at a different position within its last 4 bytes.
+	for n := 0; n <= 8; n++ {
+		msg := Message{Address: "/cross/check"}
+		for i := 0; i < n; i++ {
+			msg.Arguments = append(msg.Arguments, Int(i))
+		}
+		data := msg.Bytes()
+
+		d := NewDecoder(data)
+		if _, err := d.DecodeStringBytes(); err != nil {
+			t.Fatalf("(%d arguments) %s", n, err)
+		}
+		start := d.Offset()
+		typetags, err := d.DecodeStringBytes()
+		if err != nil {
+			t.Fatalf("(%d arguments) %s", n, err)
+		}
+		if expected, got := n+1, len(typetags); expected != got {
+			t.Fatalf("(%d arguments) expected %d type tags, got %d", n, expected, got)
+		}
+		// The type tags are followed by at least one null byte and then padding up to 4 bytes.
+		end := d.Offset()
+		if (end-start)%4 != 0 || end-start < len(typetags)+1 {
+			t.Fatalf("(%d arguments) type tag string takes up %d bytes", n, end-start)
+		}
+		for _, b := range data[start+len(typetags) : end] {
+			if b != 0 {
+				t.Fatalf("(%d arguments) expected null bytes after the type tags, got %q", n, data[start:end])
+			}
+		}
+		for i := 0; i < n; i++ {
+			v, err := d.DecodeInt32()
+			if err != nil {
+				t.Fatalf("(%d arguments) %s", n, err)
+			}
+			if expected, got := int32(i), v; expected != got {
+				t.Fatalf("(%d arguments) expected %d, got %d", n, expected, got)
+			}
+		}
+		// And the library reads back what it wrote.
+		parsed, err := ParseMessage(data, nil)
+		if err != nil {
+			t.Fatalf("(%d arguments) %s", n, err)
+		}
+		if !msg.Equal(parsed) {
+			t.Fatalf("(%d arguments) expected %+v, got %+v", n, msg, parsed)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package osc
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -20,6 +21,20 @@ func (method Method) Handle(m Message) error {
 	return method(m)
 }
 
+// HandlerFunc is another name for Method, for those used to http.HandlerFunc.
+// It lets an ordinary function be used as a MessageHandler.
+type HandlerFunc = Method
+
+// ContextMethod is an OSC method that gets the message's context as an argument,
+// see Message.Context. It is a MessageHandler, so it can be added to a dispatcher
+// like any other handler.
+type ContextMethod func(ctx context.Context, msg Message) error
+
+// Handle handles an OSC message.
+func (method ContextMethod) Handle(m Message) error {
+	return method(m.Context(), m)
+}
+
 // MessageHandler is any type that can handle an OSC message.
 type MessageHandler interface {
 	Handle(Message) error
@@ -31,60 +46,409 @@ type Dispatcher interface {
 	Invoke(msg Message, exactMatch bool) error
 }
 
+// DispatchPacket dispatches a packet that can be either a message or a bundle,
+// e.g. one returned by ReadPacket.
+// Messages are handed to d.Invoke as they are, so they are never wrapped in a bundle
+// and never wait for a timetag, and bundles are handed to d.Dispatch.
+func DispatchPacket(d Dispatcher, p Packet, exactMatch bool) error {
+	switch x := p.(type) {
+	case Message:
+		return d.Invoke(x, exactMatch)
+	case Bundle:
+		return d.Dispatch(x, exactMatch)
+	default:
+		return errors.Wrapf(ErrUnsupportedType, "%T", p)
+	}
+}
+
+// ContextDispatcher is a Dispatcher that can give up on a bundle
+// that is scheduled for the future when a context is done.
+// Serve uses DispatchContext if the dispatcher implements it,
+// so that bundles that are still waiting do not outlive the server.
+type ContextDispatcher interface {
+	Dispatcher
+	DispatchContext(ctx context.Context, bundle Bundle, exactMatch bool) error
+}
+
+// TimeHintAddress is the address of the message that carries a
+// bundle's time hint, see TimeHintDispatcher.
+const TimeHintAddress = "/time"
+
+// TimeHintDispatcher is a dispatcher that supports senders that can not set a bundle's timetag,
+// and instead send an immediate bundle whose first packet is a message
+// to TimeHintAddress with a timetag argument.
+// The rest of the packets of such a bundle are scheduled for the time in the hint.
+// Everything else is handed to the wrapped dispatcher as it is.
+type TimeHintDispatcher struct {
+	Dispatcher
+}
+
+// Dispatch dispatches a bundle, applying its time hint if it has one.
+func (d TimeHintDispatcher) Dispatch(b Bundle, exactMatch bool) error {
+	return d.Dispatcher.Dispatch(applyTimeHint(b), exactMatch)
+}
+
+// DispatchContext dispatches a bundle, applying its time hint if it has one.
+// If the wrapped dispatcher is not a ContextDispatcher ctx is ignored.
+func (d TimeHintDispatcher) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	if cd, ok := d.Dispatcher.(ContextDispatcher); ok {
+		return cd.DispatchContext(ctx, applyTimeHint(b), exactMatch)
+	}
+	return d.Dispatch(b, exactMatch)
+}
+
+// applyTimeHint returns the bundle that an immediate bundle with a time hint stands for.
+// Other bundles are returned as they are.
+func applyTimeHint(b Bundle) Bundle {
+	if b.Timetag != Immediately || len(b.Packets) == 0 {
+		return b
+	}
+	msg, ok := b.Packets[0].(Message)
+	if !ok || msg.Address != TimeHintAddress || len(msg.Arguments) != 1 {
+		return b
+	}
+	tt, ok := msg.Arguments[0].(Timetag)
+	if !ok {
+		return b
+	}
+	b.Timetag = tt
+	b.Packets = b.Packets[1:]
+	return b
+}
+
 // PatternMatching is a dispatcher that implements OSC 1.0 pattern matching.
 // See http://opensoundcontrol.org/spec-1_0 "OSC Message Dispatching and Pattern Matching"
 type PatternMatching map[string]MessageHandler
 
 // Dispatch invokes an OSC bundle's messages.
 func (h PatternMatching) Dispatch(b Bundle, exactMatch bool) error {
+	return h.DispatchContext(context.Background(), b, exactMatch)
+}
+
+// DispatchContext invokes an OSC bundle's messages.
+// If the bundle's timetag is in the future and ctx is done before then,
+// none of the messages are invoked and the context's error is returned.
+func (h PatternMatching) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	return dispatchContext(ctx, b, func(msg Message) error {
+		return h.Invoke(msg, exactMatch)
+	})
+}
+
+// Invoke invokes an OSC message.
+// Every handler whose address matches the message is invoked, in the
+// lexical order of their addresses, and all the errors are returned together.
+func (h PatternMatching) Invoke(msg Message, exactMatch bool) error {
+	_, err := h.invokeCounted(msg, exactMatch)
+	return err
+}
+
+// invokeCounted is like Invoke, but it also returns the number of handlers that matched.
+func (h PatternMatching) invokeCounted(msg Message, exactMatch bool) (int, error) {
+	return h.invokeMatching(msg, func(address string) (bool, error) {
+		return msg.Match(address, exactMatch)
+	})
+}
+
+// invokeMatching invokes the handlers whose addresses match is true for,
+// in the lexical order of their addresses.
+// Handlers that are TypedHandlers are skipped, with an error, if the message does not have their type tags.
+// It returns the number of handlers whose addresses matched.
+func (h PatternMatching) invokeMatching(msg Message, match func(address string) (bool, error)) (int, error) {
 	var (
-		now = time.Now()
-		tt  = b.Timetag.Time()
+		errs    = []error{}
+		matches = 0
 	)
-	if tt.Before(now) {
-		return h.immediately(b, exactMatch)
+	for _, address := range h.Addresses() {
+		matched, err := match(address)
+		if err != nil {
+			errs = append(errs, err)
+			return matches, joinErrors(errs)
+		}
+		if !matched {
+			continue
+		}
+		matches++
+
+		handler := h[address]
+		if err := checkTypetags(handler, msg); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := handler.Handle(msg); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	<-time.After(tt.Sub(now))
-	return h.immediately(b, exactMatch)
+	return matches, joinErrors(errs)
 }
 
-// immediately invokes an OSC bundle immediately.
-func (h PatternMatching) immediately(b Bundle, exactMatch bool) error {
-	for _, p := range b.Packets {
-		errs := []string{}
-		if err := h.invoke(p, exactMatch); err != nil {
-			errs = append(errs, err.Error())
+// MatchFunc decides whether a message should be dispatched to a handler.
+// msgAddr is the address of the message and handlerAddr is the address the handler was added for.
+type MatchFunc func(msgAddr, handlerAddr string) bool
+
+// FuncMatching is a dispatcher that uses MatchFunc, instead of OSC 1.0 pattern matching,
+// to decide which of its handlers a message is dispatched to.
+// This makes it possible to use an addressing scheme that goes beyond OSC patterns.
+// If MatchFunc is nil it behaves exactly like PatternMatching.
+type FuncMatching struct {
+	PatternMatching
+	MatchFunc MatchFunc
+}
+
+// Dispatch invokes an OSC bundle's messages.
+func (d FuncMatching) Dispatch(b Bundle, exactMatch bool) error {
+	return d.DispatchContext(context.Background(), b, exactMatch)
+}
+
+// DispatchContext invokes an OSC bundle's messages, see PatternMatching.DispatchContext.
+func (d FuncMatching) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	return dispatchContext(ctx, b, func(msg Message) error {
+		return d.Invoke(msg, exactMatch)
+	})
+}
+
+// Invoke invokes every handler that MatchFunc returns true for.
+// exactMatch is only used if MatchFunc is nil.
+func (d FuncMatching) Invoke(msg Message, exactMatch bool) error {
+	_, err := d.invokeCounted(msg, exactMatch)
+	return err
+}
+
+// invokeCounted is like Invoke, but it also returns the number of handlers that matched.
+func (d FuncMatching) invokeCounted(msg Message, exactMatch bool) (int, error) {
+	if d.MatchFunc == nil {
+		return d.PatternMatching.invokeCounted(msg, exactMatch)
+	}
+	return d.invokeMatching(msg, func(address string) (bool, error) {
+		return d.MatchFunc(msg.Address, address), nil
+	})
+}
+
+// Route is a handler of an OrderedMatching dispatcher and the address pattern it handles.
+type Route struct {
+	Address string
+	Handler MessageHandler
+}
+
+// OrderedMatching is a dispatcher that tries its routes in the order they were added
+// and only invokes the first one that matches a message, so that more specific routes
+// can be added before more general ones, e.g. /synth/1/freq before /synth/*/freq.
+// A route matches if the address of the message matches the route's address, as with PatternMatching,
+// or if the route's address is a pattern that matches the address of the message.
+type OrderedMatching []Route
+
+// Add adds a route to the end of d.
+func (d *OrderedMatching) Add(address string, handler MessageHandler) {
+	*d = append(*d, Route{Address: address, Handler: handler})
+}
+
+// Dispatch invokes an OSC bundle's messages.
+func (d OrderedMatching) Dispatch(b Bundle, exactMatch bool) error {
+	return d.DispatchContext(context.Background(), b, exactMatch)
+}
+
+// DispatchContext invokes an OSC bundle's messages, see PatternMatching.DispatchContext.
+func (d OrderedMatching) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	return dispatchContext(ctx, b, func(msg Message) error {
+		return d.Invoke(msg, exactMatch)
+	})
+}
+
+// Invoke invokes the handler of the first route that matches the message.
+// If exactMatch is true, a route only matches if its address is the same as the message's.
+func (d OrderedMatching) Invoke(msg Message, exactMatch bool) error {
+	_, err := d.invokeCounted(msg, exactMatch)
+	return err
+}
+
+// invokeCounted is like Invoke, but it also returns the number of handlers that were invoked.
+func (d OrderedMatching) invokeCounted(msg Message, exactMatch bool) (int, error) {
+	for _, route := range d {
+		matched, err := route.match(msg, exactMatch)
+		if err != nil {
+			return 0, err
 		}
-		if len(errs) > 0 {
-			return errors.New(strings.Join(errs, " and "))
+		if !matched {
+			continue
 		}
-		return nil
+		if err := checkTypetags(route.Handler, msg); err != nil {
+			return 1, err
+		}
+		return 1, route.Handler.Handle(msg)
 	}
-	return nil
+	return 0, nil
 }
 
-// invoke invokes an OSC packet, which could be a message or a bundle of messages.
-func (h PatternMatching) invoke(p Packet, exactMatch bool) error {
-	switch x := p.(type) {
-	case Message:
-		return h.Invoke(x, exactMatch)
-	case Bundle:
-		return h.immediately(x, exactMatch)
-	default:
-		return errors.Errorf("unsupported type for dispatcher: %T", p)
+// match returns true if the route matches the message.
+func (route Route) match(msg Message, exactMatch bool) (bool, error) {
+	matched, err := msg.Match(route.Address, exactMatch)
+	if err != nil || matched || exactMatch {
+		return matched, err
 	}
+	return Message{Address: route.Address}.Match(msg.Address, false)
 }
 
-// Invoke invokes an OSC message.
-func (h PatternMatching) Invoke(msg Message, exactMatch bool) error {
-	for address, handler := range h {
-		matched, err := msg.Match(address, exactMatch)
+// SpecificMatching is a dispatcher whose handlers are added for address patterns,
+// and that only invokes the most specific of the handlers that match a message:
+// the one whose pattern has the fewest wildcards, and of those the one with the longest literal prefix.
+// Ties go to the pattern that is first in lexical order.
+// E.g. a message to /a/b is dispatched to the handler for /a/b rather than the one for /a/*.
+// Handlers match messages the same way the routes of OrderedMatching do.
+type SpecificMatching map[string]MessageHandler
+
+// Dispatch invokes an OSC bundle's messages.
+func (d SpecificMatching) Dispatch(b Bundle, exactMatch bool) error {
+	return d.DispatchContext(context.Background(), b, exactMatch)
+}
+
+// DispatchContext invokes an OSC bundle's messages, see PatternMatching.DispatchContext.
+func (d SpecificMatching) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	return dispatchContext(ctx, b, func(msg Message) error {
+		return d.Invoke(msg, exactMatch)
+	})
+}
+
+// Invoke invokes the most specific handler that matches the message.
+func (d SpecificMatching) Invoke(msg Message, exactMatch bool) error {
+	_, err := d.invokeCounted(msg, exactMatch)
+	return err
+}
+
+// invokeCounted is like Invoke, but it also returns the number of handlers that were invoked.
+func (d SpecificMatching) invokeCounted(msg Message, exactMatch bool) (int, error) {
+	var (
+		best  string
+		found bool
+	)
+	for _, address := range PatternMatching(d).Addresses() {
+		matched, err := Route{Address: address}.match(msg, exactMatch)
 		if err != nil {
-			return err
+			return 0, err
+		}
+		if matched && (!found || moreSpecific(address, best)) {
+			best, found = address, true
+		}
+	}
+	if !found {
+		return 0, nil
+	}
+	handler := d[best]
+	if err := checkTypetags(handler, msg); err != nil {
+		return 1, err
+	}
+	return 1, handler.Handle(msg)
+}
+
+// patternWildcards are the characters that start a wildcard in an address pattern.
+const patternWildcards = "*?[{"
+
+// moreSpecific returns true if pattern a is more specific than pattern b:
+// it has fewer wildcards, or as many wildcards and a longer literal prefix.
+func moreSpecific(a, b string) bool {
+	wa, wb := countWildcards(a), countWildcards(b)
+	if wa != wb {
+		return wa < wb
+	}
+	return literalPrefix(a) > literalPrefix(b)
+}
+
+// countWildcards returns the number of wildcards in a pattern.
+func countWildcards(pattern string) int {
+	n := 0
+	for _, c := range pattern {
+		if strings.ContainsRune(patternWildcards, c) {
+			n++
 		}
-		if matched {
-			return handler.Handle(msg)
+	}
+	return n
+}
+
+// literalPrefix returns the length of the part of a pattern that comes before its first wildcard.
+func literalPrefix(pattern string) int {
+	if i := strings.IndexAny(pattern, patternWildcards); i >= 0 {
+		return i
+	}
+	return len(pattern)
+}
+
+// countingDispatcher is a dispatcher that can tell how many handlers a message was dispatched to.
+type countingDispatcher interface {
+	invokeCounted(msg Message, exactMatch bool) (int, error)
+}
+
+// asCountingDispatcher returns d if it can count the handlers that match a message.
+// Only the dispatchers of this package can, and not the types that embed them,
+// since those may override Invoke or Dispatch.
+func asCountingDispatcher(d Dispatcher) (countingDispatcher, bool) {
+	switch x := d.(type) {
+	case PatternMatching:
+		return x, true
+	case FuncMatching:
+		return x, true
+	case OrderedMatching:
+		return x, true
+	case SpecificMatching:
+		return x, true
+	default:
+		return nil, false
+	}
+}
+
+// dispatchContext waits for a bundle's timetag and then invokes it.
+// It gives up if ctx is done first.
+func dispatchContext(ctx context.Context, b Bundle, invoke func(Message) error) error {
+	var (
+		now = time.Now()
+		tt  = b.Timetag.Time()
+	)
+	if tt.Before(now) {
+		return immediately(b, invoke)
+	}
+	timer := time.NewTimer(tt.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return immediately(b, invoke)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// immediately invokes the messages of an OSC bundle, including those of nested bundles, immediately.
+// Every packet in the bundle is invoked, even if invoking
+// one of them fails, and all the errors are returned together.
+func immediately(b Bundle, invoke func(Message) error) error {
+	errs := []error{}
+	for _, p := range b.Packets {
+		var err error
+
+		switch x := p.(type) {
+		case Message:
+			err = invoke(x)
+		case Bundle:
+			err = immediately(x, invoke)
+		default:
+			err = errors.Errorf("unsupported type for dispatcher: %T", p)
+		}
+		if err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return joinErrors(errs)
+}
+
+// joinErrors returns an error whose message is the messages of errs joined with " and ".
+// A single error is returned as is, so that its cause is preserved.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, " and "))
 }
@@ -1,6 +1,10 @@
 package osc
 
 import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -111,7 +115,489 @@ func TestDispatcherInvoke(t *testing.T) {
 	if err := d.Invoke(Message{Address: "/baz"}, false); err != nil {
 		t.Fatal(err)
 	}
-	if err := d.invoke(badPacket{}, false); err == nil {
+	if err := d.Dispatch(Bundle{Packets: []Packet{badPacket{}}}, false); err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+// recordingDispatcher is a custom dispatcher that hands every packet it gets to a channel.
+type recordingDispatcher chan Packet
+
+func (d recordingDispatcher) Dispatch(bundle Bundle, exactMatch bool) error {
+	d <- bundle
+	return nil
+}
+
+func (d recordingDispatcher) Invoke(msg Message, exactMatch bool) error {
+	d <- msg
+	return nil
+}
+
+func TestCustomDispatcherServe(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	var (
+		dispatcher = make(recordingDispatcher, 2)
+		errChan    = make(chan error, 1)
+	)
+	go func() {
+		errChan <- server.Serve(1, dispatcher)
+	}()
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	for _, p := range []Packet{
+		Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+		Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/bar"}}},
+	} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-dispatcher:
+			if !p.Equal(got) {
+				t.Fatalf("expected %+v, got %+v", p, got)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for packet")
+		}
+	}
+}
+
+func TestDispatcherDispatchAllPackets(t *testing.T) {
+	fired := map[string]int{}
+
+	d := PatternMatching{}
+	for _, addr := range []string{"/a", "/b", "/c"} {
+		addr := addr
+		d[addr] = Method(func(msg Message) error {
+			fired[addr]++
+			if addr != "/b" {
+				return errors.Errorf("%s failed", addr)
+			}
+			return nil
+		})
+	}
+	b := Bundle{
+		Timetag: Immediately,
+		Packets: []Packet{
+			Message{Address: "/a"},
+			Message{Address: "/b"},
+			Message{Address: "/c"},
+		},
+	}
+	err := d.Dispatch(b, false)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := "/a failed and /c failed", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for _, addr := range []string{"/a", "/b", "/c"} {
+		if expected, got := 1, fired[addr]; expected != got {
+			t.Fatalf("expected %s to fire %d time(s), got %d", addr, expected, got)
+		}
+	}
+}
+
+func TestDispatcherInvokeAllMatches(t *testing.T) {
+	fired := []string{}
+
+	d := PatternMatching{}
+	for _, addr := range []string{"/synth/freq", "/synth/amp", "/fx/reverb"} {
+		addr := addr
+		d[addr] = Method(func(msg Message) error {
+			fired = append(fired, addr)
+			if addr == "/synth/amp" {
+				return errors.Errorf("%s failed", addr)
+			}
+			return nil
+		})
+	}
+	err := d.Invoke(Message{Address: "/synth/*"}, false)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := "/synth/amp failed", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if expected, got := []string{"/synth/amp", "/synth/freq"}, fired; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q to fire, got %q", expected, got)
+	}
+}
+
+func TestDispatcherDispatchContextCancel(t *testing.T) {
+	fired := make(chan struct{}, 1)
+
+	d := PatternMatching{
+		"/foo": Method(func(msg Message) error {
+			fired <- struct{}{}
+			return nil
+		}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	var (
+		start = time.Now()
+		b     = NewBundle(FromTime(start.Add(time.Hour)), Message{Address: "/foo"})
+	)
+	if expected, got := context.Canceled, d.DispatchContext(ctx, b, false); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected DispatchContext to return when the context was canceled, took %s", elapsed)
+	}
+	select {
+	case <-fired:
+		t.Fatal("expected canceled bundle not to be invoked")
+	default:
+	}
+}
+
+// startedDispatcher signals when it starts dispatching a bundle.
+type startedDispatcher struct {
+	PatternMatching
+	started chan struct{}
+}
+
+func (d startedDispatcher) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	close(d.started)
+	return d.PatternMatching.DispatchContext(ctx, b, exactMatch)
+}
+
+func TestServeAbandonsScheduledBundles(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := startedDispatcher{
+		PatternMatching: PatternMatching{"/foo": Method(func(msg Message) error { return nil })},
+		started:         make(chan struct{}),
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Serve(1, d)
+	}()
+	client, err := DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(NewBundle(FromTime(time.Now().Add(time.Hour)), Message{Address: "/foo"})); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-d.started:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for bundle to be dispatched")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeHintDispatcher(t *testing.T) {
+	var (
+		fired = make(chan time.Time, 2)
+		d     = TimeHintDispatcher{
+			Dispatcher: PatternMatching{
+				"/foo": Method(func(msg Message) error {
+					fired <- time.Now()
+					return nil
+				}),
+				TimeHintAddress: Method(func(msg Message) error {
+					return errors.New("the time hint must not be dispatched")
+				}),
+			},
+		}
+		when = time.Now().Add(50 * time.Millisecond)
+	)
+	b := NewBundle(Immediately,
+		Message{Address: TimeHintAddress, Arguments: Arguments{FromTime(when)}},
+		Message{Address: "/foo"},
+	)
+	// Make sure the hint survives the trip over the wire.
+	b, err := ParseBundle(b.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Dispatch(b, false); err != nil {
+		t.Fatal(err)
+	}
+	if early := when.Sub(<-fired); early > time.Millisecond {
+		t.Fatalf("expected the message to be rescheduled, it fired %s early", early)
+	}
+	// Bundles without a hint are dispatched as usual.
+	if err := d.Dispatch(NewBundle(Immediately, Message{Address: "/foo"}), false); err != nil {
+		t.Fatal(err)
+	}
+	<-fired
+
+	err = d.Dispatch(NewBundle(Immediately, Message{Address: TimeHintAddress, Arguments: Arguments{Int(1)}}), false)
+	if err == nil {
+		t.Fatal("expected a time hint without a timetag to be dispatched normally")
+	}
+}
+
+func TestFuncMatching(t *testing.T) {
+	fired := []string{}
+
+	handlers := PatternMatching{}
+	for _, addr := range []string{"/api/v1/play", "/api/v2/play", "/api/v2/stop"} {
+		addr := addr
+		handlers[addr] = Method(func(msg Message) error {
+			fired = append(fired, addr)
+			return nil
+		})
+	}
+	// Messages to /api/vN/x go to the handlers for every version up to N.
+	d := FuncMatching{
+		PatternMatching: handlers,
+		MatchFunc: func(msgAddr, handlerAddr string) bool {
+			var (
+				mp = strings.Split(msgAddr, "/")
+				hp = strings.Split(handlerAddr, "/")
+			)
+			return len(mp) == 4 && len(hp) == 4 && mp[1] == hp[1] && mp[3] == hp[3] && hp[2] <= mp[2]
+		},
+	}
+	if err := d.Invoke(Message{Address: "/api/v2/play"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Dispatch(NewBundle(Immediately, Message{Address: "/api/v1/stop"}, Message{Address: "/api/v3/stop"}), false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"/api/v1/play", "/api/v2/play", "/api/v2/stop"}, fired; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q to fire, got %q", expected, got)
+	}
+	// Without a MatchFunc the default OSC matching is used.
+	fired = fired[:0]
+	d.MatchFunc = nil
+
+	if err := d.Invoke(Message{Address: "/api/*/play"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"/api/v1/play", "/api/v2/play"}, fired; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q to fire, got %q", expected, got)
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	var got Message
+
+	d := PatternMatching{
+		"/x": HandlerFunc(func(m Message) error {
+			got = m
+			return nil
+		}),
+		"/y": HandlerFunc(func(m Message) error {
+			return errors.New("oops")
+		}),
+	}
+	if err := d.Invoke(Message{Address: "/x", Arguments: Arguments{Int(1)}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Message{Address: "/x", Arguments: Arguments{Int(1)}}); !expected.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	if err := d.Invoke(Message{Address: "/y"}, true); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestDispatcherTypedHandler(t *testing.T) {
+	var calls int
+
+	h := PatternMatching{
+		"/freq": WithTypetags(",f", Method(func(msg Message) error {
+			calls++
+			return nil
+		})),
+	}
+	for _, d := range []PatternMatching{h, h.Use(Recover())} {
+		calls = 0
+
+		err := d.Invoke(Message{Address: "/freq", Arguments: Arguments{Int(440)}}, false)
+		if errors.Cause(err) != ErrInvalidTypeTag {
+			t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+		}
+		if expected, got := `/freq: expected type tags "f", got "i": invalid type tag`, err.Error(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+		if err := d.Invoke(Message{Address: "/freq", Arguments: Arguments{Float(440)}}, false); err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := 1, calls; expected != got {
+			t.Fatalf("expected %d calls, got %d", expected, got)
+		}
+	}
+}
+
+func TestOrderedMatching(t *testing.T) {
+	fired := []string{}
+
+	route := func(name string) MessageHandler {
+		return Method(func(msg Message) error {
+			fired = append(fired, name+" "+msg.Address)
+			return nil
+		})
+	}
+	var d OrderedMatching
+	d.Add("/synth/1/freq", route("specific"))
+	d.Add("/synth/*/freq", route("wildcard"))
+
+	for _, addr := range []string{"/synth/1/freq", "/synth/2/freq", "/synth/1/amp"} {
+		if err := d.Invoke(Message{Address: addr}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Dispatch(NewBundle(Immediately, Message{Address: "/synth/3/freq"}), false); err != nil {
+		t.Fatal(err)
+	}
+	// Exact matching only matches routes with the same address.
+	if err := d.Invoke(Message{Address: "/synth/2/freq"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Invoke(Message{Address: "/synth/*/freq"}, true); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"specific /synth/1/freq",
+		"wildcard /synth/2/freq",
+		"wildcard /synth/3/freq",
+		"wildcard /synth/*/freq",
+	}
+	if got := fired; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q to fire, got %q", expected, got)
+	}
+	// The order the routes were added in decides which one wins.
+	fired = fired[:0]
+	d = OrderedMatching{d[1], d[0]}
+
+	if err := d.Invoke(Message{Address: "/synth/1/freq"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"wildcard /synth/1/freq"}, fired; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q to fire, got %q", expected, got)
+	}
+	if err := checkDispatcher(d, nil); err != nil {
+		t.Fatal(err)
+	}
+	d.Add("/synth/[1", route("invalid"))
+
+	if err := checkDispatcher(d, nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestSpecificMatching(t *testing.T) {
+	var fired string
+
+	d := SpecificMatching{}
+	for _, addr := range []string{"/a/b", "/a/*", "/*/b", "/a/b*", "/a/?", "/a/{b,c}", "/a/[bc]/c", "/*/*/c"} {
+		addr := addr
+		d[addr] = Method(func(msg Message) error {
+			fired = addr
+			return nil
+		})
+	}
+	for _, testcase := range []struct {
+		Address  string
+		Expected string
+	}{
+		{Address: "/a/b", Expected: "/a/b"},
+		{Address: "/a/c", Expected: "/a/*"},   // Ties go to the first in lexical order.
+		{Address: "/a/bb", Expected: "/a/b*"}, // Longer literal prefix.
+		{Address: "/x/b", Expected: "/*/b"},
+		{Address: "/a/b/c", Expected: "/a/[bc]/c"},
+		{Address: "/x/y/c", Expected: "/*/*/c"},
+	} {
+		fired = ""
+		if err := d.Invoke(Message{Address: testcase.Address}, false); err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := testcase.Expected, fired; expected != got {
+			t.Fatalf("(%s) expected %s to fire, got %s", testcase.Address, expected, got)
+		}
+	}
+	fired = ""
+	if err := d.Invoke(Message{Address: "/x/y"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if fired != "" {
+		t.Fatalf("expected nothing to fire, got %s", fired)
+	}
+	if err := checkDispatcher(d, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDispatchPacket(t *testing.T) {
+	fired := []string{}
+
+	d := PatternMatching{
+		"/foo": Method(func(msg Message) error {
+			fired = append(fired, msg.Address)
+			return nil
+		}),
+	}
+	if err := DispatchPacket(d, Message{Address: "/foo"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := DispatchPacket(d, NewBundle(Immediately, Message{Address: "/f*"}), false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"/foo", "/f*"}, fired; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err := DispatchPacket(d, badPacket{}, false); errors.Cause(err) != ErrUnsupportedType {
+		t.Fatalf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestInvokeMatchingKeepsHandlerErrors(t *testing.T) {
+	h := PatternMatching{
+		"/a": Method(func(msg Message) error { return errors.New("handler failed") }),
+		"/b": Method(func(msg Message) error { return nil }),
+	}
+	matches, err := h.invokeMatching(Message{Address: "/x"}, func(address string) (bool, error) {
+		if address == "/b" {
+			return false, errors.New("match failed")
+		}
+		return true, nil
+	})
+	if expected, got := 1, matches; expected != got {
+		t.Fatalf("expected %d matches, got %d", expected, got)
+	}
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := "handler failed and match failed", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
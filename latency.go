@@ -0,0 +1,125 @@
+package osc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBounds are the histogram bucket bounds that LatencyRecorder uses by default.
+var DefaultLatencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// Histogram counts durations in buckets.
+// Counts[i] is the number of durations d with Bounds[i-1] < d <= Bounds[i],
+// and the last element of Counts is the number of durations greater than all of the bounds,
+// so Counts has one more element than Bounds.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// NewHistogram creates a histogram with the provided bucket bounds, which must be sorted.
+func NewHistogram(bounds []time.Duration) Histogram {
+	return Histogram{
+		Bounds: append([]time.Duration(nil), bounds...),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds a duration to the histogram.
+func (h *Histogram) Observe(d time.Duration) {
+	h.Counts[h.Bucket(d)]++
+	h.Count++
+	h.Sum += d
+}
+
+// Bucket returns the index in Counts that d is counted in.
+func (h Histogram) Bucket(d time.Duration) int {
+	return sort.Search(len(h.Bounds), func(i int) bool {
+		return d <= h.Bounds[i]
+	})
+}
+
+// Mean returns the mean of the durations in the histogram.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// clone returns a copy of the histogram that does not share memory with it.
+func (h Histogram) clone() Histogram {
+	h.Bounds = append([]time.Duration(nil), h.Bounds...)
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// LatencyRecorder records how long handlers take to handle messages,
+// with one histogram per message address.
+// It is safe for concurrent use.
+type LatencyRecorder struct {
+	bounds []time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*Histogram
+}
+
+// NewLatencyRecorder creates a LatencyRecorder whose histograms have the provided bucket bounds,
+// which must be sorted. If bounds is empty DefaultLatencyBounds is used.
+func NewLatencyRecorder(bounds []time.Duration) *LatencyRecorder {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBounds
+	}
+	return &LatencyRecorder{
+		bounds: bounds,
+		stats:  map[string]*Histogram{},
+	}
+}
+
+// Middleware returns a middleware that records the execution time of the handler,
+// whether it succeeds or not.
+func (r *LatencyRecorder) Middleware() Middleware {
+	return func(handler MessageHandler) MessageHandler {
+		return Method(func(msg Message) error {
+			start := time.Now()
+			err := handler.Handle(msg)
+			r.observe(msg.Address, time.Since(start))
+			return err
+		})
+	}
+}
+
+// LatencyStats returns a snapshot of the histograms, keyed by message address.
+func (r *LatencyRecorder) LatencyStats() map[string]Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make(map[string]Histogram, len(r.stats))
+	for address, h := range r.stats {
+		stats[address] = h.clone()
+	}
+	return stats
+}
+
+// observe adds a duration to the histogram for address.
+func (r *LatencyRecorder) observe(address string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.stats[address]
+	if !ok {
+		hist := NewHistogram(r.bounds)
+		h = &hist
+		r.stats[address] = h
+	}
+	h.Observe(d)
+}
@@ -0,0 +1,72 @@
+package osc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyRecorder(t *testing.T) {
+	r := NewLatencyRecorder(nil)
+
+	d := PatternMatching{
+		"/slow": Method(func(msg Message) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}),
+		"/fast": Method(func(msg Message) error {
+			return nil
+		}),
+	}.Use(r.Middleware())
+
+	if err := d.Invoke(Message{Address: "/slow"}, true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := d.Invoke(Message{Address: "/fast"}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := r.LatencyStats()
+	if expected, got := 2, len(stats); expected != got {
+		t.Fatalf("expected %d histograms, got %d", expected, got)
+	}
+	slow := stats["/slow"]
+	if expected, got := uint64(1), slow.Count; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	// 20ms is in the (10ms, 100ms] bucket.
+	if expected, got := uint64(1), slow.Counts[3]; expected != got {
+		t.Fatalf("expected %d in bucket 3, got %v", expected, slow.Counts)
+	}
+	if mean := slow.Mean(); mean < 20*time.Millisecond {
+		t.Fatalf("expected mean of at least 20ms, got %s", mean)
+	}
+	if expected, got := uint64(3), stats["/fast"].Count; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+
+	// The stats are a snapshot.
+	slow.Counts[3] = 0
+	if expected, got := uint64(1), r.LatencyStats()["/slow"].Counts[3]; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}
+
+func TestHistogramBucket(t *testing.T) {
+	h := NewHistogram([]time.Duration{time.Millisecond, time.Second})
+
+	for _, testcase := range []struct {
+		d        time.Duration
+		expected int
+	}{
+		{d: 0, expected: 0},
+		{d: time.Millisecond, expected: 0},
+		{d: time.Millisecond + 1, expected: 1},
+		{d: time.Second, expected: 1},
+		{d: time.Minute, expected: 2},
+	} {
+		if got := h.Bucket(testcase.d); testcase.expected != got {
+			t.Fatalf("%s: expected bucket %d, got %d", testcase.d, testcase.expected, got)
+		}
+	}
+}
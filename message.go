@@ -2,11 +2,16 @@ package osc
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -25,21 +30,37 @@ type Message struct {
 	Address   string `json:"address"`
 	Arguments []Argument
 	Sender    net.Addr
+
+	ctx        context.Context
+	receivedAt time.Time
 }
 
 // ParseMessage parses an OSC message from a slice of bytes.
+// ParseMessage is safe to use with untrusted data: if the data is
+// malformed or truncated an error whose cause is ErrParse is returned.
 func ParseMessage(data []byte, sender net.Addr) (Message, error) {
-	address, idx := ReadString(data)
+	address, idx, err := readPaddedString(data)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "read address")
+	}
 	msg := Message{
-		Address: address,
-		Sender:  sender,
+		Address:    address,
+		Sender:     sender,
+		receivedAt: time.Now(),
 	}
 	data = data[idx:]
-	typetags, idx := ReadString(data)
+
+	if len(data) == 0 || data[0] != TypetagPrefix {
+		return Message{}, errors.Wrap(ErrParse, "missing type tag string")
+	}
+	typetags, idx, err := readPaddedString(data)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "read type tags")
+	}
 	data = data[idx:]
 
 	// Read all arguments.
-	args, err := ReadArguments([]byte(typetags), data)
+	args, err := parseArguments([]byte(typetags), data)
 	if err != nil {
 		return Message{}, errors.Wrap(err, "parse message")
 	}
@@ -48,11 +69,276 @@ func ParseMessage(data []byte, sender net.Addr) (Message, error) {
 	return msg, nil
 }
 
+// parseArguments reads all the arguments described by typetags from data.
+// It differs from ReadArguments in that it returns ErrParse
+// if data is shorter than the arguments it is supposed to contain.
+func parseArguments(typetags, data []byte) ([]Argument, error) {
+	args := []Argument{}
+
+	// Strip off the prefix.
+	if len(typetags) > 0 && typetags[0] == TypetagPrefix {
+		typetags = typetags[1:]
+	}
+
+	for i, tt := range typetags {
+		if err := checkArgumentSize(tt, data); err != nil {
+			return nil, errors.Wrapf(err, "read argument %d", i)
+		}
+		arg, idx, err := ReadArgument(tt, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read argument %d", i)
+		}
+		args = append(args, arg)
+		data = data[idx:]
+	}
+	return args, nil
+}
+
+// checkArgumentSize returns ErrParse if data is too short to contain
+// an argument with the provided type tag.
+func checkArgumentSize(tt byte, data []byte) error {
+	switch tt {
+	case TypetagInt, TypetagFloat:
+		if len(data) < 4 {
+			return errors.Wrapf(ErrParse, "typetag %q needs 4 bytes, %d remaining", string(tt), len(data))
+		}
+	case TypetagTimetag:
+		if len(data) < TimetagSize {
+			return errors.Wrapf(ErrParse, "typetag %q needs %d bytes, %d remaining", string(tt), TimetagSize, len(data))
+		}
+	case TypetagString:
+		if _, _, err := readPaddedString(data); err != nil {
+			return err
+		}
+	case TypetagBlob:
+		if len(data) < 4 {
+			return errors.Wrapf(ErrParse, "blob size needs 4 bytes, %d remaining", len(data))
+		}
+		length := int64(int32(byteOrder.Uint32(data)))
+		if length < 0 {
+			return errors.Wrapf(ErrParse, "negative blob size %d", length)
+		}
+		if padded := (length + 3) &^ 3; padded > int64(len(data)-4) {
+			return errors.Wrapf(ErrParse, "truncated blob: size %d needs %d bytes with padding, %d remaining", length, padded, len(data)-4)
+		}
+	}
+	return nil
+}
+
+// NewMessagef creates a message whose arguments are described by format,
+// a type tag string such as "ifs" (the leading comma is optional).
+// Each argument is converted the same way AppendMap converts values
+// and its type tag has to match the corresponding type tag in format.
+func NewMessagef(addr, format string, args ...interface{}) (Message, error) {
+	format = strings.TrimPrefix(format, string(TypetagPrefix))
+	if len(format) != len(args) {
+		return Message{}, errors.Errorf("format %q has %d type tags, got %d arguments", format, len(format), len(args))
+	}
+	msg := Message{
+		Address:   addr,
+		Arguments: make(Arguments, len(args)),
+	}
+	for i, v := range args {
+		arg, err := toArgument(v)
+		if err != nil {
+			return Message{}, errors.Wrapf(err, "argument %d (%T)", i, v)
+		}
+		if tt := arg.Typetag(); tt != format[i] {
+			return Message{}, errors.Errorf("argument %d: expected type tag %c, got %c", i, format[i], tt)
+		}
+		msg.Arguments[i] = arg
+	}
+	return msg, nil
+}
+
+// NewMessage creates a message with the provided address and arguments.
+// The address is not validated, see NewMessageSafe.
+func NewMessage(addr string, args ...Argument) Message {
+	return Message{
+		Address:   addr,
+		Arguments: append(Arguments{}, args...),
+	}
+}
+
+// NewMessageSafe is like NewMessage, but it returns an error whose cause is
+// ErrInvalidAddress if addr is not a valid address pattern (see ValidatePattern).
+// Use it when the address comes from user input.
+func NewMessageSafe(addr string, args ...Argument) (Message, error) {
+	if err := ValidatePattern(addr); err != nil {
+		return Message{}, err
+	}
+	return NewMessage(addr, args...), nil
+}
+
+// ErrorMessage creates a standard error reply to a request that was sent to requestAddr.
+// Its address is ErrorAddress and its arguments are requestAddr and text, as strings.
+// This is the same reply that servers send when error replies are enabled.
+func ErrorMessage(requestAddr, text string) Message {
+	return NewMessage(ErrorAddress, String(requestAddr), String(text))
+}
+
+// ValidatePattern returns an error whose cause is ErrInvalidAddress if pattern
+// is not a valid OSC address pattern, i.e. an address that a message can be sent to.
+// Unlike ValidateAddress it allows the pattern matching characters, but every part
+// of the pattern has to be well-formed, e.g. brackets and braces have to be closed.
+// A concrete address that passes ValidateAddress also passes ValidatePattern.
+func ValidatePattern(pattern string) error {
+	if len(pattern) == 0 || pattern[0] != MessageChar {
+		return errors.Wrapf(ErrInvalidAddress, "pattern %q must begin with %c", pattern, MessageChar)
+	}
+	if i := strings.IndexAny(pattern, " #"); i >= 0 {
+		return errors.Wrapf(ErrInvalidAddress, "pattern %q contains %q", pattern, pattern[i])
+	}
+	for _, part := range strings.Split(pattern[1:], string(MessageChar)) {
+		if _, err := compilePart(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddressSegments returns the parts of the message's address,
+// e.g. []string{"synth", "freq"} for /synth/freq.
+// The root address "/" has no segments.
+func (msg Message) AddressSegments() []string {
+	addr := strings.TrimPrefix(msg.Address, string(MessageChar))
+	if len(addr) == 0 {
+		return []string{}
+	}
+	return strings.Split(addr, string(MessageChar))
+}
+
+// AppendMap appends the values in m to the message's arguments
+// in the order given by the keys in order.
+// The values are converted to OSC arguments: int32 and int become Int,
+// float32 and float64 become Float, string becomes String, bool becomes Bool,
+// and []byte becomes Blob. Values that are already an Argument are appended as they are.
+// If a key is missing from m or a value can not be converted
+// then an error is returned and no arguments are appended.
+func (msg *Message) AppendMap(m map[string]interface{}, order []string) error {
+	args := make(Arguments, 0, len(order))
+	for _, key := range order {
+		v, ok := m[key]
+		if !ok {
+			return errors.Errorf("key %q not found", key)
+		}
+		arg, err := toArgument(v)
+		if err != nil {
+			return errors.Wrapf(err, "key %q (%T)", key, v)
+		}
+		args = append(args, arg)
+	}
+	msg.Arguments = append(msg.Arguments, args...)
+	return nil
+}
+
+// WriteArguments appends args to the message's arguments.
+// The values are converted the same way AppendMap converts them.
+// If one of them can not be converted then an error that says which one is returned
+// (e.g. "argument 2 (complex128): unsupported type") and no arguments are appended.
+func (msg *Message) WriteArguments(args ...interface{}) error {
+	converted := make(Arguments, len(args))
+	for i, v := range args {
+		arg, err := toArgument(v)
+		if err != nil {
+			return errors.Wrapf(err, "argument %d (%T)", i, v)
+		}
+		converted[i] = arg
+	}
+	msg.Arguments = append(msg.Arguments, converted...)
+	return nil
+}
+
+// WriteTime appends t to the message's arguments as a timetag.
+// The zero time is written as the "immediately" timetag, see FromTime.
+func (msg *Message) WriteTime(t time.Time) {
+	msg.Arguments = append(msg.Arguments, FromTime(t))
+}
+
+// Reset makes the message an empty message to addr, as if it had just been created,
+// but keeps the memory of its arguments for the arguments that are added next.
+// This makes it possible to reuse messages, e.g. with a sync.Pool, in loops that send a lot of them.
+// A message must not be reset while it, or a copy of it, is still being used.
+func (msg *Message) Reset(addr string) {
+	for i := range msg.Arguments {
+		msg.Arguments[i] = nil // Do not hold on to the old arguments.
+	}
+	*msg = Message{
+		Address:   addr,
+		Arguments: msg.Arguments[:0],
+	}
+}
+
+// Quantize rounds all the float arguments of the message to the given number of decimal places.
+// The result is still a 32-bit float, so it is the closest float32 to the rounded value.
+func (msg *Message) Quantize(decimals int) {
+	scale := math.Pow10(decimals)
+
+	for i, arg := range msg.Arguments {
+		f, ok := arg.(Float)
+		if !ok {
+			continue
+		}
+		msg.Arguments[i] = Float(math.Round(float64(f)*scale) / scale)
+	}
+}
+
+// SetInt32At replaces the argument at index i with an Int.
+func (msg *Message) SetInt32At(i int, v int32) error {
+	return msg.setAt(i, Int(v))
+}
+
+// SetFloat32At replaces the argument at index i with a Float.
+func (msg *Message) SetFloat32At(i int, v float32) error {
+	return msg.setAt(i, Float(v))
+}
+
+// SetBoolAt replaces the argument at index i with a Bool.
+func (msg *Message) SetBoolAt(i int, v bool) error {
+	return msg.setAt(i, Bool(v))
+}
+
+// SetStringAt replaces the argument at index i with a String.
+func (msg *Message) SetStringAt(i int, v string) error {
+	return msg.setAt(i, String(v))
+}
+
+// SetBlobAt replaces the argument at index i with a Blob.
+func (msg *Message) SetBlobAt(i int, v []byte) error {
+	return msg.setAt(i, Blob(v))
+}
+
+// setAt replaces the argument at index i.
+// The new argument does not have to have the same type as the old one,
+// since the type tags and the binary representation of a message
+// are derived from its arguments whenever it is serialized.
+func (msg *Message) setAt(i int, arg Argument) error {
+	if i < 0 || i >= len(msg.Arguments) {
+		return ErrIndexOutOfBounds
+	}
+	msg.Arguments[i] = arg
+	return nil
+}
+
 // Bytes returns the contents of the message as a slice of bytes.
 func (msg Message) Bytes() []byte {
+	return msg.bytes(msg.Typetags())
+}
+
+// BytesOmitTypetagPrefix returns the contents of the message as a slice of bytes,
+// but leaves out the ',' that starts the type tag string.
+// WARNING: this violates the OSC 1.0 spec!
+// It only exists for ultra-minimal receivers that reject the comma.
+// Use Bytes for everything else.
+func (msg Message) BytesOmitTypetagPrefix() []byte {
+	return msg.bytes(msg.typetags(false))
+}
+
+// bytes returns the contents of the message using the provided type tags.
+func (msg Message) bytes(typetags []byte) []byte {
 	b := [][]byte{
 		ToBytes(msg.Address),
-		msg.Typetags(),
+		typetags,
 	}
 	for _, a := range msg.Arguments {
 		b = append(b, a.Bytes())
@@ -60,6 +346,40 @@ func (msg Message) Bytes() []byte {
 	return bytes.Join(b, []byte{})
 }
 
+// clone returns a copy of the message that does not share any memory with it:
+// the argument slice and the bytes of blob arguments are copied.
+func (msg Message) clone() Message {
+	args := make(Arguments, len(msg.Arguments))
+	for i, a := range msg.Arguments {
+		if blob, ok := a.(Blob); ok {
+			a = Blob(append([]byte{}, blob...))
+		}
+		args[i] = a
+	}
+	msg.Arguments = args
+	return msg
+}
+
+// Clone returns a copy of the message that can be changed without changing msg,
+// e.g. to send a template message repeatedly with different arguments.
+// Arguments of types this package does not know are copied by encoding and
+// decoding them, which fails if their type tag is not supported.
+func (msg Message) Clone() (*Message, error) {
+	c := msg.clone()
+	for i, a := range c.Arguments {
+		switch a.(type) {
+		case Int, Float, Bool, String, Blob, Nil, Impulse, Timetag:
+			continue
+		}
+		arg, _, err := ReadArgument(a.Typetag(), a.Bytes())
+		if err != nil {
+			return nil, errors.Wrapf(err, "clone argument %d", i)
+		}
+		c.Arguments[i] = arg
+	}
+	return &c, nil
+}
+
 // Equal returns true if the messages are equal, false otherwise.
 func (msg Message) Equal(other Packet) bool {
 	msg2, ok := other.(Message)
@@ -81,6 +401,14 @@ func (msg Message) Equal(other Packet) bool {
 }
 
 // Match returns true if the address of the OSC Message matches the given address.
+//
+// If exactMatch is true the two addresses are compared byte for byte, without
+// any pattern expansion and without case folding. This is faster, and it is
+// what servers whose handlers are all registered with literal addresses want.
+//
+// If exactMatch is false the message's address is treated as an OSC address pattern and is matched
+// against address one part at a time, where parts are separated by '/'.
+// This means that '*' and '?' never match across a '/'.
 func (msg Message) Match(address string, exactMatch bool) (bool, error) {
 	if exactMatch {
 		return address == msg.Address, nil
@@ -89,56 +417,298 @@ func (msg Message) Match(address string, exactMatch bool) (bool, error) {
 	if !VerifyParts(address, msg.Address) {
 		return false, nil
 	}
-	exp, err := GetRegex(msg.Address)
+	var (
+		mc       = string(MessageChar)
+		patterns = strings.Split(msg.Address, mc)
+		parts    = strings.Split(address, mc)
+	)
+	for i, pattern := range patterns {
+		matched, err := matchPart(pattern, parts[i])
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchPart returns true if a single part of an address matches
+// the corresponding part of an address pattern.
+func matchPart(pattern, part string) (bool, error) {
+	if pattern == part {
+		return true, nil
+	}
+	exp, err := partRegexp(pattern)
 	if err != nil {
 		return false, err
 	}
-	return exp.MatchString(address), nil
+	return exp.MatchString(part), nil
+}
+
+// maxCachedPatterns limits the size of the pattern cache.
+// Patterns come from the network, so the cache must not grow without bound.
+const maxCachedPatterns = 4096
+
+// patternCache holds the compiled regular expressions for pattern parts.
+var patternCache struct {
+	m    sync.Map // map[string]*regexp.Regexp
+	size int64
+}
+
+// partRegexp returns the compiled regular expression for a part of an address pattern.
+// Compiled expressions are cached, since the same patterns tend to arrive over and over.
+// It is safe to call partRegexp from multiple goroutines.
+func partRegexp(pattern string) (*regexp.Regexp, error) {
+	if exp, ok := patternCache.m.Load(pattern); ok {
+		return exp.(*regexp.Regexp), nil
+	}
+	exp, err := compilePart(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.LoadInt64(&patternCache.size) < maxCachedPatterns {
+		if _, loaded := patternCache.m.LoadOrStore(pattern, exp); !loaded {
+			atomic.AddInt64(&patternCache.size, 1)
+		}
+	}
+	return exp, nil
+}
+
+// compilePart compiles a part of an address pattern to a regular expression.
+func compilePart(pattern string) (*regexp.Regexp, error) {
+	expr, err := translatePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidAddress, "pattern %q: %s", pattern, err)
+	}
+	return exp, nil
+}
+
+// translatePattern translates an OSC address pattern to a regular expression.
+// '*' matches any sequence of characters other than '/', '?' matches any
+// single character other than '/', [...] matches a character in a set (see translateClass),
+// and {foo,bar} matches one of the comma-separated strings.
+// Everything else is matched literally.
+func translatePattern(pattern string) (string, error) {
+	var expr strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr.WriteString("[^/]*")
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			class, n, err := translateClass(pattern[i:])
+			if err != nil {
+				return "", errors.Wrapf(err, "pattern %q", pattern)
+			}
+			expr.WriteString(class)
+			i += n - 1
+		case '{':
+			end := strings.IndexByte(pattern[i+1:], '}')
+			if end == -1 {
+				return "", errors.Wrapf(ErrInvalidAddress, "pattern %q: unterminated {", pattern)
+			}
+			alternatives := strings.Split(pattern[i+1:i+1+end], ",")
+			for j, alt := range alternatives {
+				alternatives[j] = regexp.QuoteMeta(alt)
+			}
+			expr.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String(), nil
+}
+
+// ReceivedAt returns the time the message was received.
+// Messages that are dispatched by Serve are stamped when the packet
+// containing them is read, and other parsed messages are stamped
+// when they are parsed. Messages that were not received have a zero time.
+func (msg Message) ReceivedAt() time.Time {
+	return msg.receivedAt
+}
+
+// Context returns the context of the message, which is never nil.
+// Messages that are dispatched by Serve have a context that is done when serving stops,
+// e.g. because the connection was closed, so that long-running handlers can give up.
+// Other messages have the background context unless they were created with WithContext.
+func (msg Message) Context() context.Context {
+	if msg.ctx == nil {
+		return context.Background()
+	}
+	return msg.ctx
+}
+
+// WithContext returns a copy of the message whose context is ctx.
+// ctx must not be nil.
+func (msg Message) WithContext(ctx context.Context) Message {
+	if ctx == nil {
+		panic("nil context")
+	}
+	msg.ctx = ctx
+	return msg
+}
+
+// SenderIP returns the IP address of the sender of the message,
+// or nil if the message has no sender or was not received over UDP or TCP.
+func (msg Message) SenderIP() net.IP {
+	switch addr := msg.Sender.(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
+
+// SenderPort returns the port of the sender of the message,
+// or 0 if the message has no sender or was not received over UDP or TCP.
+func (msg Message) SenderPort() int {
+	switch addr := msg.Sender.(type) {
+	case *net.UDPAddr:
+		return addr.Port
+	case *net.TCPAddr:
+		return addr.Port
+	default:
+		return 0
+	}
+}
+
+// stamp sets the receive time and the context of a message,
+// or of every message in a bundle (including nested bundles).
+// A zero time or a nil context is left alone.
+func stamp(p Packet, receivedAt time.Time, ctx context.Context) Packet {
+	switch x := p.(type) {
+	case Message:
+		if !receivedAt.IsZero() {
+			x.receivedAt = receivedAt
+		}
+		if ctx != nil {
+			x.ctx = ctx
+		}
+		return x
+	case Bundle:
+		packets := make([]Packet, len(x.Packets))
+		for i, p := range x.Packets {
+			packets[i] = stamp(p, receivedAt, ctx)
+		}
+		x.Packets = packets
+		return x
+	default:
+		return p
+	}
+}
+
+// Print writes a human-readable representation of the message to w:
+// the address, the type tags, and then each argument as text
+// (see ArgumentReader.ReadAsString), all separated by spaces.
+func (msg Message) Print(w io.Writer) error {
+	fields := []string{msg.Address, string(msg.typetags(true)[:len(msg.Arguments)+1])}
+
+	for r := msg.Reader(); r.Len() > 0; {
+		s, err := r.ReadAsString()
+		if err != nil {
+			return err
+		}
+		fields = append(fields, s)
+	}
+	_, err := fmt.Fprintln(w, strings.Join(fields, " "))
+	return err
 }
 
 // Typetags returns a padded byte slice of the message's type tags.
 func (msg Message) Typetags() []byte {
-	tt := make([]byte, len(msg.Arguments)+1)
-	tt[0] = TypetagPrefix
-	for i, a := range msg.Arguments {
-		tt[i+1] = a.Typetag()
+	return msg.typetags(true)
+}
+
+// typetags returns a padded byte slice of the message's type tags.
+// If prefix is false the leading ',' is omitted.
+func (msg Message) typetags(prefix bool) []byte {
+	tt := make([]byte, 0, len(msg.Arguments)+2)
+	if prefix {
+		tt = append(tt, TypetagPrefix)
+	}
+	for _, a := range msg.Arguments {
+		tt = append(tt, a.Typetag())
 	}
 	return Pad(append(tt, 0))
 }
 
-// WriteTo writes the Message to an io.Writer.
+// WriteTo writes the binary representation of the message to an io.Writer,
+// the same bytes that Bytes returns.
+// The address and the type tags are written as separately padded OSC-strings.
 func (msg Message) WriteTo(w io.Writer) (int64, error) {
-	var bytesWritten int
+	var bytesWritten int64
 
-	nw, err := fmt.Fprintf(w, "%s%s", msg.Address, msg.Typetags())
-	if err != nil {
-		return 0, err
+	for _, b := range [][]byte{ToBytes(msg.Address), msg.Typetags()} {
+		nw, err := w.Write(b)
+		bytesWritten += int64(nw)
+		if err != nil {
+			return bytesWritten, err
+		}
 	}
-	bytesWritten += nw
-
 	for _, a := range msg.Arguments {
-		nw, err := a.WriteTo(w)
+		nw, err := w.Write(a.Bytes())
+		bytesWritten += int64(nw)
 		if err != nil {
-			return 0, err
+			return bytesWritten, err
 		}
-		bytesWritten += int(nw)
 	}
-
-	return int64(bytesWritten), nil
+	return bytesWritten, nil
 }
 
 // GetRegex compiles and returns a regular expression object for the given address pattern.
 func GetRegex(pattern string) (*regexp.Regexp, error) {
-	pattern = strings.Replace(pattern, ".", "\\.", -1) // Escape all '.' in the pattern
-	pattern = strings.Replace(pattern, "(", "\\(", -1) // Escape all '(' in the pattern
-	pattern = strings.Replace(pattern, ")", "\\)", -1) // Escape all ')' in the pattern
-	pattern = strings.Replace(pattern, "*", ".*", -1)  // Replace a '*' with '.*' that matches zero or more characters
-	pattern = strings.Replace(pattern, "{", "(", -1)   // Change a '{' to '('
-	pattern = strings.Replace(pattern, ",", "|", -1)   // Change a ',' to '|'
-	pattern = strings.Replace(pattern, "}", ")", -1)   // Change a '}' to ')'
-	pattern = strings.Replace(pattern, "?", ".", -1)   // Change a '?' to '.'
-	pattern = "^" + pattern + "$"
-	return regexp.Compile(pattern)
+	expr, err := translatePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^" + expr + "$")
+}
+
+// translateClass translates the character class at the start of pattern,
+// e.g. [abc] or [a-z], to a regular expression. If the first character
+// after the '[' is '!' the class is negated, and a ']' that comes first
+// (after the optional '!') is a literal ']' instead of the end of the class.
+// It returns the regular expression and the number of bytes of pattern it consumed.
+func translateClass(pattern string) (string, int, error) {
+	var (
+		class strings.Builder
+		i     = 1
+	)
+	class.WriteByte('[')
+
+	if i < len(pattern) && pattern[i] == '!' {
+		class.WriteByte('^')
+		i++
+	}
+	for first := true; ; first = false {
+		if i >= len(pattern) {
+			return "", 0, errors.Wrap(ErrInvalidAddress, "unterminated [")
+		}
+		c := pattern[i]
+		i++
+
+		if c == ']' && !first {
+			break
+		}
+		switch c {
+		case '\\', '[', ']', '^':
+			class.WriteByte('\\')
+		}
+		class.WriteByte(c)
+	}
+	class.WriteByte(']')
+	return class.String(), i, nil
 }
 
 // VerifyParts verifies that m1 and m2 have the same number of parts,
@@ -162,3 +732,194 @@ func VerifyParts(m1, m2 string) bool {
 	}
 	return true
 }
+
+// ExpandPattern returns all the addresses in known that match pattern.
+// This is useful for resolving a pattern to the concrete addresses
+// in an address space, e.g. "/ch/*/mute" to "/ch/1/mute", "/ch/2/mute", etc.
+// The returned addresses are in the same order as they appear in known.
+func ExpandPattern(pattern string, known []string) ([]string, error) {
+	var (
+		msg     = Message{Address: pattern}
+		matches = []string{}
+	)
+	for _, addr := range known {
+		matched, err := msg.Match(addr, false)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, addr)
+		}
+	}
+	return matches, nil
+}
+
+// PatternsOverlap returns true if there is a concrete address that both of the address patterns match.
+// This can be used to detect handlers that would both be invoked for the same messages.
+func PatternsOverlap(a, b string) (bool, error) {
+	if !VerifyParts(a, b) {
+		return false, nil
+	}
+	var (
+		mc     = string(MessageChar)
+		aparts = strings.Split(a, mc)
+		bparts = strings.Split(b, mc)
+	)
+	for i, apart := range aparts {
+		overlap, err := partsOverlap(apart, bparts[i])
+		if err != nil {
+			return false, err
+		}
+		if !overlap {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// patternToken is a single element of a part of an address pattern:
+// either a '*' or a set of the bytes that match one byte of the address.
+type patternToken struct {
+	star bool
+	set  [256]bool
+}
+
+// partsOverlap returns true if there is a string that both pattern parts match.
+func partsOverlap(a, b string) (bool, error) {
+	aseqs, err := tokenizePart(a)
+	if err != nil {
+		return false, err
+	}
+	bseqs, err := tokenizePart(b)
+	if err != nil {
+		return false, err
+	}
+	for _, aseq := range aseqs {
+		for _, bseq := range bseqs {
+			if sequencesOverlap(aseq, bseq) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// sequencesOverlap returns true if there is a string that both token sequences match.
+// It explores the states (i, j) of matching a prefix of the string against
+// a[:i] and b[:j] at the same time.
+func sequencesOverlap(a, b []patternToken) bool {
+	type state struct{ i, j int }
+
+	var (
+		seen  = map[state]bool{}
+		queue = []state{{}}
+	)
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		if s.i == len(a) && s.j == len(b) {
+			return true
+		}
+		// A '*' can match nothing.
+		if s.i < len(a) && a[s.i].star {
+			queue = append(queue, state{s.i + 1, s.j})
+		}
+		if s.j < len(b) && b[s.j].star {
+			queue = append(queue, state{s.i, s.j + 1})
+		}
+		// Or both patterns consume the same byte.
+		if s.i == len(a) || s.j == len(b) || !tokensIntersect(a[s.i], b[s.j]) {
+			continue
+		}
+		next := state{s.i + 1, s.j + 1}
+		if a[s.i].star {
+			next.i = s.i
+		}
+		if b[s.j].star {
+			next.j = s.j
+		}
+		queue = append(queue, next)
+	}
+	return false
+}
+
+// tokensIntersect returns true if there is a byte that both tokens match.
+func tokensIntersect(a, b patternToken) bool {
+	for c := 0; c < 256; c++ {
+		if (a.star || a.set[c]) && (b.star || b.set[c]) && c != MessageChar {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenizePart splits a part of an address pattern into token sequences.
+// There is one sequence for every combination of {...} alternatives in the part.
+func tokenizePart(pattern string) ([][]patternToken, error) {
+	seqs := [][]patternToken{{}}
+
+	appendAll := func(tokens ...patternToken) {
+		for i := range seqs {
+			seqs[i] = append(seqs[i], tokens...)
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			appendAll(patternToken{star: true})
+		case '?':
+			tok := patternToken{}
+			for c := range tok.set {
+				tok.set[c] = c != MessageChar
+			}
+			appendAll(tok)
+		case '[':
+			class, n, err := translateClass(pattern[i:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "pattern %q", pattern)
+			}
+			exp, err := regexp.Compile("^" + class + "$")
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidAddress, "pattern %q: %s", pattern, err)
+			}
+			tok := patternToken{}
+			for c := 0; c < 128; c++ {
+				tok.set[c] = exp.MatchString(string(rune(c)))
+			}
+			appendAll(tok)
+			i += n - 1
+		case '{':
+			end := strings.IndexByte(pattern[i+1:], '}')
+			if end == -1 {
+				return nil, errors.Wrapf(ErrInvalidAddress, "pattern %q: unterminated {", pattern)
+			}
+			expanded := [][]patternToken{}
+			for _, alt := range strings.Split(pattern[i+1:i+1+end], ",") {
+				for _, seq := range seqs {
+					seq = append(append([]patternToken{}, seq...), literalTokens(alt)...)
+					expanded = append(expanded, seq)
+				}
+			}
+			seqs = expanded
+			i += end + 1
+		default:
+			appendAll(literalTokens(string(c))...)
+		}
+	}
+	return seqs, nil
+}
+
+// literalTokens returns the tokens that match s literally.
+func literalTokens(s string) []patternToken {
+	tokens := make([]patternToken, len(s))
+	for i := 0; i < len(s); i++ {
+		tokens[i].set[s[i]] = true
+	}
+	return tokens
+}
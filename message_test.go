@@ -2,9 +2,14 @@ package osc
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -44,11 +49,11 @@ func TestMessageEqual(t *testing.T) {
 		m1, m2 := testcase.M1, testcase.M2
 		if testcase.Expected {
 			if !m1.Equal(m2) {
-				t.Fatalf("expected %s to equal %s", m1, m2)
+				t.Fatalf("expected %+v to equal %+v", m1, m2)
 			}
 		} else {
 			if m1.Equal(m2) {
-				t.Fatalf("expected %s to not equal %s", m1, m2)
+				t.Fatalf("expected %+v to not equal %+v", m1, m2)
 			}
 		}
 	}
@@ -83,6 +88,11 @@ func TestMatch(t *testing.T) {
 		{"/path/to/*", "/path/to/method"},
 		{"/path/to/method*", "/path/to/method"},
 		{"/path/to/m[aei]thod", "/path/to/method"},
+		{"/a?c", "/abc"},
+		{"/*/to/*", "/path/to/method"},
+		{"/path/to/*thod", "/path/to/method"},
+		{"/path/to/{foo,method}", "/path/to/method"},
+		{"/path/to/me.hod", "/path/to/me.hod"},
 	} {
 		msg := Message{Address: pair[0]}
 		match, err := msg.Match(pair[1], false)
@@ -101,6 +111,10 @@ func TestMatch(t *testing.T) {
 		{"/path/to?method", "/path/to/method"},
 		{"/path/to*", "/path/to/method"},
 		{"/path/to/[domet]", "/path/to/method"},
+		{"/foo/*", "/foo/bar/baz"},
+		{"/a?c", "/abbc"},
+		{"/p*", "/path/to/method"},
+		{"/path/to/me.hod", "/path/to/method"},
 	} {
 		msg := Message{Address: pair[0]}
 		match, err := msg.Match(pair[1], false)
@@ -129,6 +143,21 @@ func TestMatchExactly(t *testing.T) {
 			Addr:        "/foo/bar",
 			ShouldMatch: true,
 		},
+		{
+			Msg:         Message{Address: "/foo/Bar"},
+			Addr:        "/foo/bar",
+			ShouldMatch: false,
+		},
+		{
+			Msg:         Message{Address: "/foo/*"},
+			Addr:        "/foo/bar",
+			ShouldMatch: false,
+		},
+		{
+			Msg:         Message{Address: "/foo/*"},
+			Addr:        "/foo/*",
+			ShouldMatch: true,
+		},
 	} {
 		var (
 			msg  = testcase.Msg
@@ -151,10 +180,41 @@ func TestMatchExactly(t *testing.T) {
 	}
 }
 
+func TestMatchModes(t *testing.T) {
+	msg := Message{Address: "/foo/*"}
+
+	// With pattern matching the '*' in the message address expands.
+	match, err := msg.Match("/foo/bar", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Fatal("expected /foo/* to match /foo/bar")
+	}
+	// With exact matching it is just another character.
+	match, err = msg.Match("/foo/bar", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match {
+		t.Fatal("expected /foo/* to not match /foo/bar exactly")
+	}
+}
+
 func TestGetRegex(t *testing.T) {
 	if _, err := GetRegex(`[`); err == nil {
 		t.Fatalf("expected error, got nil")
 	}
+	exp, err := GetRegex("/foo/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exp.MatchString("/foo/bar") {
+		t.Fatal("expected /foo/* to match /foo/bar")
+	}
+	if exp.MatchString("/foo/bar/baz") {
+		t.Fatal("expected /foo/* to not match /foo/bar/baz")
+	}
 }
 
 func TestMesssageBytes(t *testing.T) {
@@ -185,6 +245,35 @@ func TestMesssageBytes(t *testing.T) {
 	}
 }
 
+func TestMessageBytesOmitTypetagPrefix(t *testing.T) {
+	msg := Message{
+		Address:   "/foo",
+		Arguments: []Argument{Int(1), String("bar")},
+	}
+	var (
+		conformant = msg.Bytes()
+		compat     = msg.BytesOmitTypetagPrefix()
+		addrLen    = len(ToBytes(msg.Address))
+	)
+	if expected, got := byte(TypetagPrefix), conformant[addrLen]; expected != got {
+		t.Fatalf("expected %c, got %c", expected, got)
+	}
+	// Deleting the comma from the conformant type tag string and re-padding it
+	// should produce exactly the comma-less output.
+	var (
+		typetags = msg.Typetags()
+		tail     = conformant[addrLen+len(typetags):]
+		stripped = Pad(append(bytes.TrimRight(typetags[1:], "\x00"), 0))
+		expected = bytes.Join([][]byte{conformant[:addrLen], stripped, tail}, []byte{})
+	)
+	if !bytes.Equal(expected, compat) {
+		t.Fatalf("expected %q, got %q", expected, compat)
+	}
+	if bytes.Equal(conformant, compat) {
+		t.Fatal("expected output without the type tag prefix to differ from conformant output")
+	}
+}
+
 type errWriter struct {
 	erridx int
 	curr   int
@@ -215,6 +304,41 @@ func TestMessageWriteTo(t *testing.T) {
 	}
 }
 
+func TestMessageBytesLiblo(t *testing.T) {
+	// Produced by liblo with lo_send(addr, "/foo", "ifsb", 1, 2.5f, "bar", blob)
+	// where blob holds the bytes 1, 2, 3, 4, 5.
+	liblo := []byte{
+		'/', 'f', 'o', 'o',
+		0, 0, 0, 0,
+		',', 'i', 'f', 's',
+		'b', 0, 0, 0,
+		0, 0, 0, 1,
+		0x40, 0x20, 0, 0,
+		'b', 'a', 'r', 0,
+		0, 0, 0, 5,
+		1, 2, 3, 4,
+		5, 0, 0, 0,
+	}
+	msg := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Int(1), Float(2.5), String("bar"), Blob{1, 2, 3, 4, 5}},
+	}
+	if got := msg.Bytes(); !bytes.Equal(liblo, got) {
+		t.Fatalf("expected %q, got %q", liblo, got)
+	}
+	var buf bytes.Buffer
+	n, err := msg.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int64(len(liblo)), n; expected != got {
+		t.Fatalf("expected %d bytes written, got %d", expected, got)
+	}
+	if got := buf.Bytes(); !bytes.Equal(liblo, got) {
+		t.Fatalf("expected %q, got %q", liblo, got)
+	}
+}
+
 func TestParseMessage(t *testing.T) {
 	type Input struct {
 		data   []byte
@@ -245,7 +369,7 @@ func TestParseMessage(t *testing.T) {
 					Address: "/foo",
 					Arguments: []Argument{
 						Int(1),
-						Blob([]byte{'b', 'a', 'r', 0}),
+						Blob([]byte{'b', 'a', 'r'}),
 					},
 				},
 			},
@@ -269,9 +393,796 @@ func TestParseMessage(t *testing.T) {
 				t.Fatalf("(testcase %d) %s", i, err)
 			}
 			if expected, got := testcase.Expected.Message, msg; !expected.Equal(got) {
-				t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+				t.Fatalf("(testcase %d) expected %+v, got %+v", i, expected, got)
 			}
 		} else {
 		}
 	}
 }
+
+func TestParseMessageMalformed(t *testing.T) {
+	for i, data := range [][]byte{
+		nil,
+		{},
+		[]byte("/foo"),                   // unterminated address
+		{'/', 'f', 'o', 'o', 0},          // address missing padding
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0}, // no type tag string
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, 'i', 0, 0}, // type tags without a comma
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'i'},  // unterminated type tags
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'i', 0, 0, 0, 0},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'f', 0, 0, 0x40},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 's', 0, 0, 'b', 'a', 'r'},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 's', 0, 0, 'b', 'a', 'r', 'b', 0},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'b', 0, 0, 0, 0},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'b', 0, 0, 0, 0, 0, 8, 'b', 'a', 'r', 0},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'b', 0, 0, 0xFF, 0xFF, 0xFF, 0xFC},
+		{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'i', 'i', 0, 0, 0, 0, 1, 0, 0},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("(testcase %d) panic parsing %q: %v", i, data, r)
+				}
+			}()
+			_, err := ParseMessage(data, nil)
+			if err == nil {
+				t.Fatalf("(testcase %d) expected error, got nil", i)
+			}
+			if errors.Cause(err) != ErrParse {
+				t.Fatalf("(testcase %d) expected ErrParse, got %s", i, err)
+			}
+		}()
+	}
+}
+
+func TestExpandPattern(t *testing.T) {
+	known := []string{
+		"/ch/1/mute",
+		"/ch/1/fader",
+		"/ch/2/mute",
+		"/ch/10/mute",
+		"/bus/1/mute",
+	}
+	for i, testcase := range []struct {
+		Pattern  string
+		Expected []string
+	}{
+		{
+			Pattern:  "/ch/*/mute",
+			Expected: []string{"/ch/1/mute", "/ch/2/mute", "/ch/10/mute"},
+		},
+		{
+			Pattern:  "/ch/?/mute",
+			Expected: []string{"/ch/1/mute", "/ch/2/mute"},
+		},
+		{
+			Pattern:  "/*/1/mute",
+			Expected: []string{"/ch/1/mute", "/bus/1/mute"},
+		},
+		{
+			Pattern:  "/ch/1/fader",
+			Expected: []string{"/ch/1/fader"},
+		},
+		{
+			Pattern:  "/ch/*",
+			Expected: []string{},
+		},
+	} {
+		got, err := ExpandPattern(testcase.Pattern, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := testcase.Expected; strings.Join(expected, " ") != strings.Join(got, " ") {
+			t.Fatalf("(testcase %d) expected %v, got %v", i, expected, got)
+		}
+	}
+	if _, err := ExpandPattern("/ch/[/mute", known); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestMessageAppendMap(t *testing.T) {
+	var (
+		msg = Message{Address: "/synth", Arguments: Arguments{String("sine")}}
+		m   = map[string]interface{}{
+			"freq":  440.0,
+			"amp":   float32(0.5),
+			"voice": 3,
+			"label": "lead",
+			"gate":  true,
+			"data":  []byte{1, 2},
+			"chan":  Int(7),
+		}
+		order = []string{"voice", "freq", "amp", "gate", "label", "data", "chan"}
+	)
+	if err := msg.AppendMap(m, order); err != nil {
+		t.Fatal(err)
+	}
+	expected := Message{
+		Address: "/synth",
+		Arguments: Arguments{
+			String("sine"),
+			Int(3),
+			Float(440),
+			Float(0.5),
+			Bool(true),
+			String("lead"),
+			Blob([]byte{1, 2}),
+			Int(7),
+		},
+	}
+	if !expected.Equal(msg) {
+		t.Fatalf("expected %+v, got %+v", expected, msg)
+	}
+}
+
+func TestMessageAppendMapError(t *testing.T) {
+	for i, testcase := range []struct {
+		Map   map[string]interface{}
+		Order []string
+		Err   string
+	}{
+		{
+			Map:   map[string]interface{}{"a": 1},
+			Order: []string{"a", "b"},
+			Err:   `key "b" not found`,
+		},
+		{
+			Map:   map[string]interface{}{"a": 1, "b": complex(1, 2)},
+			Order: []string{"a", "b"},
+			Err:   `key "b" (complex128): unsupported type`,
+		},
+	} {
+		msg := Message{Address: "/foo"}
+		err := msg.AppendMap(testcase.Map, testcase.Order)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if expected, got := 0, len(msg.Arguments); expected != got {
+			t.Fatalf("(testcase %d) expected %d arguments, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestNewMessagef(t *testing.T) {
+	msg, err := NewMessagef("/s_new", ",sifbT", "sine", 1000, float32(440), []byte{1}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Message{
+		Address:   "/s_new",
+		Arguments: Arguments{String("sine"), Int(1000), Float(440), Blob{1}, Bool(true)},
+	}
+	if !expected.Equal(msg) {
+		t.Fatalf("expected %+v, got %+v", expected, msg)
+	}
+}
+
+func TestNewMessagefError(t *testing.T) {
+	for i, testcase := range []struct {
+		Format string
+		Args   []interface{}
+		Err    string
+	}{
+		{
+			Format: "if",
+			Args:   []interface{}{1},
+			Err:    `format "if" has 2 type tags, got 1 arguments`,
+		},
+		{
+			Format: "if",
+			Args:   []interface{}{1, "foo"},
+			Err:    `argument 1: expected type tag f, got s`,
+		},
+		{
+			Format: "i",
+			Args:   []interface{}{complex(1, 2)},
+			Err:    `argument 0 (complex128): unsupported type`,
+		},
+	} {
+		_, err := NewMessagef("/foo", testcase.Format, testcase.Args...)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Err, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
+
+func TestMessagePrint(t *testing.T) {
+	msg := Message{
+		Address:   "/n_set",
+		Arguments: Arguments{Int(1000), Nil{}, Impulse{}, String("gate")},
+	}
+	// Nil and Impulse have no data, so the arguments after them must still parse.
+	parsed, err := ParseMessage(msg.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(parsed) {
+		t.Fatalf("expected %+v, got %+v", msg, parsed)
+	}
+	buf := &bytes.Buffer{}
+	if err := parsed.Print(buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/n_set ,iNIs 1000 nil impulse gate\n", buf.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestMessageReceivedAt(t *testing.T) {
+	if at := (Message{Address: "/foo"}).ReceivedAt(); !at.IsZero() {
+		t.Fatalf("expected zero time, got %s", at)
+	}
+	before := time.Now()
+	msg, err := ParseMessage(Message{Address: "/foo"}.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := msg.ReceivedAt(); at.Before(before) || at.After(time.Now()) {
+		t.Fatalf("expected receive time between %s and now, got %s", before, at)
+	}
+}
+
+func TestMatchCharacterClass(t *testing.T) {
+	for i, testcase := range []struct {
+		Pattern string
+		Address string
+		Match   bool
+	}{
+		{Pattern: "/fader[1-8]", Address: "/fader3", Match: true},
+		{Pattern: "/fader[1-8]", Address: "/fader9", Match: false},
+		{Pattern: "/fader[1-8]", Address: "/fader", Match: false},
+		{Pattern: "/fader[1-8a-c]", Address: "/faderb", Match: true},
+		{Pattern: "/ch[!0]", Address: "/ch1", Match: true},
+		{Pattern: "/ch[!0]", Address: "/ch0", Match: false},
+		{Pattern: "/ch[!0-4]", Address: "/ch5", Match: true},
+		{Pattern: "/ch[!0-4]", Address: "/ch2", Match: false},
+		{Pattern: "/a[]]", Address: "/a]", Match: true},
+		{Pattern: "/a[]b]", Address: "/ab", Match: true},
+		{Pattern: "/a[!]]", Address: "/a]", Match: false},
+		{Pattern: "/a[!]]", Address: "/ax", Match: true},
+		{Pattern: "/a[^]", Address: "/a^", Match: true},
+		{Pattern: "/a[!^]", Address: "/a^", Match: false},
+		{Pattern: "/a[\\]", Address: "/a\\", Match: true},
+		{Pattern: "/a[.]", Address: "/ab", Match: false},
+		{Pattern: "/a[-]", Address: "/a-", Match: true},
+	} {
+		msg := Message{Address: testcase.Pattern}
+		match, err := msg.Match(testcase.Address, false)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Match, match; expected != got {
+			t.Fatalf("(testcase %d) expected match of %s and %s to be %t, got %t", i, testcase.Pattern, testcase.Address, expected, got)
+		}
+	}
+	for _, pattern := range []string{"/a[", "/a[]", "/a[!", "/a[z-a]"} {
+		msg := Message{Address: pattern}
+		if _, err := msg.Match("/ab", false); errors.Cause(err) != ErrInvalidAddress {
+			t.Fatalf("(pattern %s) expected ErrInvalidAddress, got %+v", pattern, err)
+		}
+	}
+}
+
+func TestMatchAlternation(t *testing.T) {
+	for i, testcase := range []struct {
+		Pattern string
+		Address string
+		Match   bool
+	}{
+		{Pattern: "/track/{mute,solo}", Address: "/track/mute", Match: true},
+		{Pattern: "/track/{mute,solo}", Address: "/track/solo", Match: true},
+		{Pattern: "/track/{mute,solo}", Address: "/track/arm", Match: false},
+		{Pattern: "/track/{mute,solo,arm}", Address: "/track/arm", Match: true},
+		{Pattern: "/track/{mute,solo}", Address: "/track/mutesolo", Match: false},
+		{Pattern: "/{a,b}*/x", Address: "/abc/x", Match: true},
+		{Pattern: "/{a,b}*/x", Address: "/b/x", Match: true},
+		{Pattern: "/{a,b}*/x", Address: "/cab/x", Match: false},
+		{Pattern: "/{a,b}*/x", Address: "/a/b/x", Match: false},
+		{Pattern: "/ch/{1,2}/{mute,solo}", Address: "/ch/2/solo", Match: true},
+		{Pattern: "/ch/{1,2}?", Address: "/ch/1x", Match: true},
+		{Pattern: "/{a.b,c}", Address: "/axb", Match: false},
+		{Pattern: "/x{,y}", Address: "/x", Match: true},
+	} {
+		msg := Message{Address: testcase.Pattern}
+		match, err := msg.Match(testcase.Address, false)
+		if err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		if expected, got := testcase.Match, match; expected != got {
+			t.Fatalf("(testcase %d) expected match of %s and %s to be %t, got %t", i, testcase.Pattern, testcase.Address, expected, got)
+		}
+	}
+	msg := Message{Address: "/track/{mute,solo"}
+	if _, err := msg.Match("/track/mute", false); errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %+v", err)
+	}
+}
+
+func TestPartRegexpCached(t *testing.T) {
+	const pattern = "fader[1-8]*"
+
+	exp1, err := partRegexp(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp2, err := partRegexp(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp1 != exp2 {
+		t.Fatal("expected the compiled pattern to be reused")
+	}
+	// The cached expression matches exactly like a freshly compiled one.
+	fresh, err := compilePart(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"fader1", "fader8x", "fader9", "fader", "Fader1"} {
+		if expected, got := fresh.MatchString(part), exp2.MatchString(part); expected != got {
+			t.Fatalf("(part %s) expected %t, got %t", part, expected, got)
+		}
+	}
+	if _, err := partRegexp("fader["); errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %+v", err)
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	msg := Message{Address: "/mixer/ch[1-8]/{mute,solo}"}
+
+	for i := 0; i < b.N; i++ {
+		if _, err := msg.Match("/mixer/ch3/solo", false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMatchUncached(b *testing.B) {
+	var (
+		patterns = strings.Split("/mixer/ch[1-8]/{mute,solo}", "/")
+		parts    = strings.Split("/mixer/ch3/solo", "/")
+	)
+	for i := 0; i < b.N; i++ {
+		for j, pattern := range patterns {
+			if pattern == parts[j] {
+				continue
+			}
+			exp, err := compilePart(pattern)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = exp.MatchString(parts[j])
+		}
+	}
+}
+
+func TestMessageSetAt(t *testing.T) {
+	msg := Message{
+		Address:   "/mixer/gain",
+		Arguments: Arguments{Int(1), Float(0.5), String("post")},
+	}
+	if err := msg.SetFloat32At(1, 0.25); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseMessage(msg.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Message{
+		Address:   "/mixer/gain",
+		Arguments: Arguments{Int(1), Float(0.25), String("post")},
+	}
+	if !expected.Equal(parsed) {
+		t.Fatalf("expected %+v, got %+v", expected, parsed)
+	}
+	// Changing the type of an argument changes the type tags.
+	if err := msg.SetStringAt(0, "ch1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.SetBlobAt(2, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.SetBoolAt(1, true); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := ",sTb", strings.TrimRight(string(msg.Typetags()), "\x00"); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if err := msg.SetInt32At(3, 1); err != ErrIndexOutOfBounds {
+		t.Fatalf("expected ErrIndexOutOfBounds, got %+v", err)
+	}
+	if err := msg.SetInt32At(-1, 1); err != ErrIndexOutOfBounds {
+		t.Fatalf("expected ErrIndexOutOfBounds, got %+v", err)
+	}
+}
+
+func TestMessageAddressSegments(t *testing.T) {
+	for _, testcase := range []struct {
+		Address  string
+		Expected []string
+	}{
+		{Address: "/", Expected: []string{}},
+		{Address: "/synth", Expected: []string{"synth"}},
+		{Address: "/synth/freq", Expected: []string{"synth", "freq"}},
+	} {
+		got := Message{Address: testcase.Address}.AddressSegments()
+		if expected := testcase.Expected; !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(address %s) expected %q, got %q", testcase.Address, expected, got)
+		}
+	}
+}
+
+func TestMessageRoot(t *testing.T) {
+	msg, err := ParseMessage(Message{Address: "/"}.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "/", msg.Address; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	for _, testcase := range []struct {
+		Pattern string
+		Address string
+		Match   bool
+	}{
+		{Pattern: "/", Address: "/", Match: true},
+		{Pattern: "/", Address: "/foo", Match: false},
+		{Pattern: "/foo", Address: "/", Match: false},
+		{Pattern: "/*", Address: "/", Match: false},
+	} {
+		match, err := Message{Address: testcase.Pattern}.Match(testcase.Address, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := testcase.Match, match; expected != got {
+			t.Fatalf("expected match of %s and %s to be %t, got %t", testcase.Pattern, testcase.Address, expected, got)
+		}
+	}
+}
+
+func TestPatternsOverlap(t *testing.T) {
+	for _, testcase := range []struct {
+		A, B     string
+		Expected bool
+	}{
+		{A: "/a/*", B: "/a/b", Expected: true},
+		{A: "/a/b", B: "/a/c", Expected: false},
+		{A: "/a/b", B: "/a/b", Expected: true},
+		{A: "/a/*", B: "/b/*", Expected: false},
+		{A: "/a/*", B: "/a/b/c", Expected: false},
+		{A: "/a/foo*", B: "/a/*bar", Expected: true},
+		{A: "/a/foo*", B: "/a/bar*", Expected: false},
+		{A: "/a/f?o", B: "/a/*x*", Expected: true},
+		{A: "/a/f?o", B: "/a/*x", Expected: false},
+		{A: "/a/[a-c]", B: "/a/[c-e]", Expected: true},
+		{A: "/a/[a-c]", B: "/a/[d-f]", Expected: false},
+		{A: "/a/[!a]", B: "/a/a", Expected: false},
+		{A: "/a/{foo,bar}", B: "/a/b*", Expected: true},
+		{A: "/a/{foo,bar}", B: "/a/baz", Expected: false},
+		{A: "/a/?", B: "/a/", Expected: false},
+	} {
+		got, err := PatternsOverlap(testcase.A, testcase.B)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := testcase.Expected; expected != got {
+			t.Fatalf("(%s and %s) expected %t, got %t", testcase.A, testcase.B, expected, got)
+		}
+		got, err = PatternsOverlap(testcase.B, testcase.A)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := testcase.Expected; expected != got {
+			t.Fatalf("(%s and %s) expected %t, got %t", testcase.B, testcase.A, expected, got)
+		}
+	}
+	if _, err := PatternsOverlap("/a/[b", "/a/b"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestMessageClone(t *testing.T) {
+	var (
+		blob = []byte{1, 2, 3}
+		msg  = Message{Address: "/foo", Arguments: Arguments{Int(1), Blob(blob)}}
+		orig = Message{Address: "/foo", Arguments: Arguments{Int(1), Blob([]byte{1, 2, 3})}}
+	)
+	clone := msg.clone()
+
+	if err := clone.SetInt32At(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	clone.Arguments[1].(Blob)[0] = 9
+
+	if !orig.Equal(msg) {
+		t.Fatalf("expected the original to be unchanged, got %+v", msg)
+	}
+	if expected, got := (Message{Address: "/foo", Arguments: Arguments{Int(2), Blob([]byte{9, 2, 3})}}), clone; !expected.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMessageCloneExported(t *testing.T) {
+	template := Message{Address: "/synth/note", Arguments: Arguments{Int(60), Blob([]byte{1, 2}), String("saw")}}
+
+	clone, err := template.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.Address = "/synth/other"
+	if err := clone.SetInt32At(0, 61); err != nil {
+		t.Fatal(err)
+	}
+	clone.Arguments[1].(Blob)[0] = 9
+	clone.Arguments = append(clone.Arguments, Float(0.5))
+
+	if expected, got := (Message{Address: "/synth/note", Arguments: Arguments{Int(60), Blob([]byte{1, 2}), String("saw")}}), template; !expected.Equal(got) {
+		t.Fatalf("expected the template to be unchanged, got %+v", got)
+	}
+	if expected, got := (Message{Address: "/synth/other", Arguments: Arguments{Int(61), Blob([]byte{9, 2}), String("saw"), Float(0.5)}}), *clone; !expected.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+
+	// Arguments of unknown types are copied by decoding their bytes.
+	if _, err := (Message{Address: "/foo", Arguments: Arguments{customArg{Int: 1}}}).Clone(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Message{Address: "/foo", Arguments: Arguments{customArg{Int(1), 'x'}}}).Clone(); errors.Cause(err) != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %v", err)
+	}
+}
+
+// customArg is an Argument of a type the package does not know.
+type customArg struct {
+	Int
+	typetag byte
+}
+
+func (a customArg) Typetag() byte {
+	if a.typetag == 0 {
+		return TypetagInt
+	}
+	return a.typetag
+}
+
+func TestMessageWriteArguments(t *testing.T) {
+	msg := Message{Address: "/foo", Arguments: Arguments{Int(0)}}
+
+	if err := msg.WriteArguments(int32(1), 2.5, "bar", true, []byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Int(0), Int(1), Float(2.5), String("bar"), Bool(true), Blob([]byte{1}), Nil{}},
+	}
+	if !expected.Equal(msg) {
+		t.Fatalf("expected %+v, got %+v", expected, msg)
+	}
+	err := msg.WriteArguments(int32(1), "bar", complex(1, 2), "baz")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := "argument 2 (complex128): unsupported type", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if errors.Cause(err) != ErrUnsupportedType {
+		t.Fatalf("expected ErrUnsupportedType, got %+v", err)
+	}
+	if !expected.Equal(msg) {
+		t.Fatalf("expected no arguments to be appended, got %+v", msg)
+	}
+}
+
+func TestMessageQuantize(t *testing.T) {
+	msg := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Float(0.12345), Int(7), Float(1.23456), Float(-2.0004), String("bar"), Float(10)},
+	}
+	msg.Quantize(3)
+
+	expected := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Float(0.123), Int(7), Float(1.235), Float(-2), String("bar"), Float(10)},
+	}
+	if !bytes.Equal(expected.Bytes(), msg.Bytes()) {
+		t.Fatalf("expected %q, got %q", expected.Bytes(), msg.Bytes())
+	}
+	for i, arg := range msg.Arguments {
+		if !expected.Arguments[i].Equal(arg) {
+			t.Fatalf("argument %d: expected %s, got %s", i, expected.Arguments[i], arg)
+		}
+	}
+}
+
+func TestNewMessageSafe(t *testing.T) {
+	for _, addr := range []string{
+		"/",
+		"/foo",
+		"/mixer/ch1/gain",
+		"/foo/*",
+		"/foo/ba?",
+		"/foo/[a-z]",
+		"/foo/{bar,baz}",
+	} {
+		msg, err := NewMessageSafe(addr, Int(1))
+		if err != nil {
+			t.Fatalf("(address %q) %s", addr, err)
+		}
+		if expected := NewMessage(addr, Int(1)); !expected.Equal(msg) {
+			t.Fatalf("(address %q) expected %+v, got %+v", addr, expected, msg)
+		}
+	}
+	for _, addr := range []string{
+		"",
+		"foo",
+		"foo/bar",
+		"/foo bar",
+		"/foo#bar",
+		"/foo/[bar",
+		"/foo/{bar,baz",
+	} {
+		if _, err := NewMessageSafe(addr); errors.Cause(err) != ErrInvalidAddress {
+			t.Fatalf("(address %q) expected ErrInvalidAddress, got %+v", addr, err)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	msg := ErrorMessage("/synth/new", "no such synthdef")
+
+	if expected, got := ErrorAddress, msg.Address; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	r := msg.Reader()
+
+	for _, expected := range []string{"/synth/new", "no such synthdef"} {
+		s, err := r.ReadString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected != s {
+			t.Fatalf("expected %s, got %s", expected, s)
+		}
+	}
+	if expected, got := 0, r.Len(); expected != got {
+		t.Fatalf("expected %d arguments left, got %d", expected, got)
+	}
+}
+
+func TestMessageReset(t *testing.T) {
+	msg := Message{
+		Address:    "/foo",
+		Arguments:  Arguments{Int(1), String("bar"), Blob{1, 2, 3}},
+		Sender:     &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 57120},
+		receivedAt: time.Now(),
+	}
+	msg.Reset("/baz")
+
+	fresh := NewMessage("/baz")
+	if !fresh.Equal(msg) {
+		t.Fatalf("expected %+v, got %+v", fresh, msg)
+	}
+	if !bytes.Equal(fresh.Bytes(), msg.Bytes()) {
+		t.Fatalf("expected %q, got %q", fresh.Bytes(), msg.Bytes())
+	}
+	if msg.Sender != nil || !msg.ReceivedAt().IsZero() {
+		t.Fatalf("expected sender and received time to be reset, got %+v", msg)
+	}
+	if err := msg.WriteArguments(float32(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.WriteArguments(float32(2)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fresh.Bytes(), msg.Bytes()) {
+		t.Fatalf("expected %q, got %q", fresh.Bytes(), msg.Bytes())
+	}
+}
+
+func BenchmarkMessageReset(b *testing.B) {
+	pool := sync.Pool{
+		New: func() interface{} { return &Message{} },
+	}
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		msg := pool.Get().(*Message)
+		msg.Reset("/synth/params")
+		msg.Arguments = append(msg.Arguments, Int(i), Float(440), String("sine"))
+		if _, err := msg.WriteTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+		pool.Put(msg)
+	}
+}
+
+func BenchmarkMessageNew(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		msg := NewMessage("/synth/params", Int(i), Float(440), String("sine"))
+		if _, err := msg.WriteTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMessageSenderIPPort(t *testing.T) {
+	for i, testcase := range []struct {
+		Sender net.Addr
+		IP     net.IP
+		Port   int
+	}{
+		{Sender: &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 57120}, IP: net.IPv4(192, 168, 1, 2), Port: 57120},
+		{Sender: &net.TCPAddr{IP: net.IPv6loopback, Port: 8000}, IP: net.IPv6loopback, Port: 8000},
+		{Sender: &net.UnixAddr{Name: "/tmp/osc.sock", Net: "unixgram"}},
+		{Sender: nil},
+	} {
+		msg := Message{Address: "/foo", Sender: testcase.Sender}
+
+		if expected, got := testcase.IP, msg.SenderIP(); !expected.Equal(got) {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+		if expected, got := testcase.Port, msg.SenderPort(); expected != got {
+			t.Fatalf("(testcase %d) expected %d, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestMessageContext(t *testing.T) {
+	msg := Message{Address: "/foo"}
+	if expected, got := context.Background(), msg.Context(); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	withCtx := msg.WithContext(ctx)
+	if expected, got := ctx, withCtx.Context(); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if expected, got := context.Background(), msg.Context(); expected != got {
+		t.Fatalf("expected the original message to keep its context, got %v", got)
+	}
+}
+
+func FuzzParseMessage(f *testing.F) {
+	for _, msg := range []Message{
+		{Address: "/"},
+		{Address: "/foo", Arguments: Arguments{Int(1), Float(2.5), String("bar"), Blob([]byte{1, 2, 3})}},
+		{Address: "/bar", Arguments: Arguments{Bool(true), Bool(false), Nil{}, Impulse{}, Immediately}},
+	} {
+		f.Add(msg.Bytes())
+	}
+	for _, data := range [][]byte{
+		{},
+		{'/'},
+		{'/', 'f', 'o', 'o'},
+		{'/', 0, 0, 0},
+		{'/', 0, 0, 0, ',', 'i', 0, 0},
+		{'/', 0, 0, 0, ',', 's', 0, 0, 'a', 'b'},
+		{'/', 0, 0, 0, ',', 'b', 0, 0, 0xff, 0xff, 0xff, 0xff},
+		{'/', 0, 0, 0, ',', 'b', 0, 0, 0, 0, 0, 8, 1, 2},
+		{'/', 0, 0, 0, ',', 't', 0, 0, 1, 2, 3},
+		{'/', 0, 0, 0, ',', 'x', 0, 0},
+	} {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := ParseMessage(data, nil)
+		if err != nil {
+			return
+		}
+		// A message that parses has to survive being serialized and parsed again.
+		if _, err := ParseMessage(msg.Bytes(), nil); err != nil {
+			t.Fatalf("parse %q: %s", msg.Bytes(), err)
+		}
+	})
+}
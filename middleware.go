@@ -0,0 +1,109 @@
+package osc
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrHandlerPanic = errors.New("handler panicked")
+)
+
+// Middleware wraps a MessageHandler, e.g. to add logging or metrics to it.
+type Middleware func(MessageHandler) MessageHandler
+
+// Chain combines middleware into a single Middleware.
+// The middleware are applied in order, so the first one is the outermost:
+// it sees each message first and each error last.
+func Chain(mw ...Middleware) Middleware {
+	return func(handler MessageHandler) MessageHandler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			handler = mw[i](handler)
+		}
+		return handler
+	}
+}
+
+// Use returns a new PatternMatching whose handlers are the
+// handlers of h wrapped with the provided middleware, see Chain.
+// Wrapped TypedHandlers keep their type tags.
+// It must be called after all of the handlers have been added.
+func (h PatternMatching) Use(mw ...Middleware) PatternMatching {
+	chain := Chain(mw...)
+
+	return h.wrap(func(address string, handler MessageHandler) MessageHandler {
+		return chain(handler)
+	})
+}
+
+// LogFunc is called after a handler has handled a message with the address the handler was added for,
+// which is not necessarily the address of the message, how long it took and the error it returned.
+type LogFunc func(address string, dur time.Duration, err error)
+
+// Log returns a new PatternMatching whose handlers are the handlers of h,
+// but report every message they handle to fn.
+// Like Use, it must be called after all of the handlers have been added.
+// Without Log there is no overhead.
+func (h PatternMatching) Log(fn LogFunc) PatternMatching {
+	return h.wrap(func(address string, handler MessageHandler) MessageHandler {
+		return Method(func(msg Message) error {
+			start := time.Now()
+			err := handler.Handle(msg)
+			fn(address, time.Since(start), err)
+			return err
+		})
+	})
+}
+
+// wrap returns a new PatternMatching whose handlers are the handlers of h
+// replaced with the result of wrapper. Wrapped TypedHandlers keep their type tags.
+func (h PatternMatching) wrap(wrapper func(address string, handler MessageHandler) MessageHandler) PatternMatching {
+	wrapped := make(PatternMatching, len(h))
+
+	for address, handler := range h {
+		if th, ok := handler.(TypedHandler); ok {
+			wrapped[address] = WithTypetags(th.Typetags(), wrapper(address, handler))
+			continue
+		}
+		wrapped[address] = wrapper(address, handler)
+	}
+	return wrapped
+}
+
+// Recover returns a middleware that converts panics in the handler into errors.
+// The errors wrap ErrHandlerPanic.
+func Recover() Middleware {
+	return func(handler MessageHandler) MessageHandler {
+		return Method(func(msg Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = errors.Wrapf(ErrHandlerPanic, "%s: %v", msg.Address, r)
+				}
+			}()
+			return handler.Handle(msg)
+		})
+	}
+}
+
+// DeadLetter returns a middleware that hands the messages that the handler fails to handle
+// to deadLetter, e.g. to inspect them or to retry them later, instead of returning the error.
+// deadLetter gets a copy of the message, so it is unaffected by anything the handler did to it.
+// If deadLetter returns an error as well, both errors are returned.
+func DeadLetter(deadLetter MessageHandler) Middleware {
+	return func(handler MessageHandler) MessageHandler {
+		return Method(func(msg Message) error {
+			orig := msg.clone()
+
+			err := handler.Handle(msg)
+			if err == nil {
+				return nil
+			}
+			if dlErr := deadLetter.Handle(orig); dlErr != nil {
+				return errors.Wrapf(dlErr, "dead letter for error %q", err)
+			}
+			return nil
+		})
+	}
+}
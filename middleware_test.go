@@ -0,0 +1,138 @@
+package osc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestChain(t *testing.T) {
+	calls := []string{}
+
+	trace := func(name string) Middleware {
+		return func(handler MessageHandler) MessageHandler {
+			return Method(func(msg Message) error {
+				calls = append(calls, name+" before")
+				err := handler.Handle(msg)
+				calls = append(calls, name+" after")
+				return err
+			})
+		}
+	}
+	d := PatternMatching{
+		"/foo": Method(func(msg Message) error {
+			calls = append(calls, "handler")
+			return nil
+		}),
+	}.Use(trace("a"), trace("b"))
+
+	if err := d.Invoke(Message{Address: "/foo"}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a before", "b before", "handler", "b after", "a after"}
+	if got := calls; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	d := PatternMatching{
+		"/panic": Method(func(msg Message) error {
+			panic("oops")
+		}),
+		"/ok": Method(func(msg Message) error {
+			return nil
+		}),
+	}.Use(Recover())
+
+	err := d.Invoke(Message{Address: "/panic"}, false)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := ErrHandlerPanic, errors.Cause(err); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if expected, got := "/panic: oops: handler panicked", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if err := d.Invoke(Message{Address: "/ok"}, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var (
+		dead = []Message{}
+		orig = Message{Address: "/fail", Arguments: Arguments{Int(1), Blob([]byte{1, 2})}}
+	)
+	d := PatternMatching{
+		"/fail": Method(func(msg Message) error {
+			msg.Arguments[1].(Blob)[0] = 9 // Scribble on the message before failing.
+			return errors.New("oops")
+		}),
+		"/ok": Method(func(msg Message) error {
+			return nil
+		}),
+	}.Use(DeadLetter(Method(func(msg Message) error {
+		dead = append(dead, msg)
+		return nil
+	})))
+
+	if err := d.Invoke(Message{Address: "/ok"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Invoke(orig.clone(), false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(dead); expected != got {
+		t.Fatalf("expected %d dead letters, got %d", expected, got)
+	}
+	if expected, got := orig, dead[0]; !expected.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	// An error from the dead letter handler is returned.
+	d = PatternMatching{
+		"/fail": Method(func(msg Message) error { return errors.New("oops") }),
+	}.Use(DeadLetter(Method(func(msg Message) error { return errors.New("no room") })))
+
+	err := d.Invoke(Message{Address: "/fail"}, false)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if expected, got := `dead letter for error "oops": no room`, err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPatternMatchingLog(t *testing.T) {
+	type entry struct {
+		address string
+		err     error
+	}
+	var (
+		entries []entry
+		oops    = errors.New("oops")
+	)
+	d := PatternMatching{
+		"/synth/freq": Method(func(msg Message) error { return nil }),
+		"/synth/gate": Method(func(msg Message) error { return oops }),
+	}.Log(func(address string, dur time.Duration, err error) {
+		if dur < 0 {
+			t.Errorf("expected a non-negative duration, got %s", dur)
+		}
+		entries = append(entries, entry{address: address, err: err})
+	})
+
+	if err := d.Invoke(Message{Address: "/synth/*"}, false); err != oops {
+		t.Fatalf("expected oops, got %+v", err)
+	}
+	expected := []entry{
+		{address: "/synth/freq"},
+		{address: "/synth/gate", err: oops},
+	}
+	if !reflect.DeepEqual(expected, entries) {
+		t.Fatalf("expected %+v, got %+v", expected, entries)
+	}
+}
@@ -0,0 +1,32 @@
+package osc
+
+import (
+	"context"
+	"net"
+)
+
+// ListenMulticastUDP creates a UDP server that joins the multicast group gaddr.
+// If ifi is nil the system-assigned multicast interface is used.
+func ListenMulticastUDP(network string, gaddr *net.UDPAddr, ifi *net.Interface) (*UDPConn, error) {
+	return ListenMulticastUDPContext(context.Background(), network, gaddr, ifi)
+}
+
+// ListenMulticastUDPContext creates a multicast UDP listener that can be canceled with the provided context.
+func ListenMulticastUDPContext(ctx context.Context, network string, gaddr *net.UDPAddr, ifi *net.Interface) (*UDPConn, error) {
+	conn, err := net.ListenMulticastUDP(network, ifi, gaddr)
+	if err != nil {
+		return nil, err
+	}
+	uc := &UDPConn{
+		udpConn:   conn,
+		closeChan: make(chan struct{}),
+		ctx:       ctx,
+		errChan:   make(chan error),
+	}
+	return uc.initialize()
+}
+
+// DialMulticast creates a new OSC connection that sends to the multicast group gaddr.
+func DialMulticast(network string, laddr, gaddr *net.UDPAddr) (*UDPConn, error) {
+	return DialUDPContext(context.Background(), network, laddr, gaddr)
+}
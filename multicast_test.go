@@ -1,78 +1,95 @@
 package osc
 
 import (
+	"net"
 	"testing"
+	"time"
 )
 
-func TestMulticastSend(t *testing.T) {
-	// const group = "224.10.10.1:9999"
-
-	// // BUG(briansorahan): How to reliably test multicast everywhere?
-	// ifi, err := net.InterfaceByIndex(4)
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
-	// gaddr, err := net.ResolveUDPAddr("udp", group)
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
-	// server1, err := ListenMulticastUDP("udp", ifi, gaddr)
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
-	// defer func() { _ = server1.Close() }() // Best effort.
+// multicastInterface returns an interface that can be used to test multicast.
+// The loopback interface is preferred, but it is not multicast-capable on every system.
+func multicastInterface(t *testing.T) *net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *net.Interface
+	for i, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if ifi.Flags&net.FlagLoopback != 0 {
+			return &ifaces[i]
+		}
+		if found == nil {
+			found = &ifaces[i]
+		}
+	}
+	if found == nil {
+		t.Skip("no multicast-capable interface")
+	}
+	return found
+}
 
-	// server2, err := ListenMulticastUDP("udp", ifi, gaddr)
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
-	// defer func() { _ = server2.Close() }() // Best effort.
+func TestMulticastSend(t *testing.T) {
+	ifi := multicastInterface(t)
 
-	// var (
-	// 	errChan = make(chan error)
-	// 	wg      = &sync.WaitGroup{}
-	// )
+	gaddr, err := net.ResolveUDPAddr("udp4", "239.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server1, err := ListenMulticastUDP("udp4", gaddr, ifi)
+	if err != nil {
+		t.Skipf("could not join multicast group: %s", err)
+	}
+	defer func() { _ = server1.Close() }() // Best effort.
 
-	// wg.Add(2)
+	// Both listeners have to share the port the first one was given.
+	gaddr.Port = server1.LocalAddr().(*net.UDPAddr).Port
 
-	// go func() {
-	// 	errChan <- server1.Serve(map[string]Method{
-	// 		"/mcast/method": func(msg *Message) error {
-	// 			wg.Done()
-	// 			return nil
-	// 		},
-	// 	})
-	// }()
+	server2, err := ListenMulticastUDP("udp4", gaddr, ifi)
+	if err != nil {
+		t.Skipf("could not join multicast group: %s", err)
+	}
+	defer func() { _ = server2.Close() }() // Best effort.
 
-	// go func() {
-	// 	errChan <- server2.Serve(map[string]Method{
-	// 		"/mcast/method": func(msg *Message) error {
-	// 			wg.Done()
-	// 			return nil
-	// 		},
-	// 	})
-	// }()
+	var (
+		errChan = make(chan error, 2)
+		msgChan = make(chan Message, 2)
+	)
+	for _, server := range []*UDPConn{server1, server2} {
+		go func(server *UDPConn) {
+			errChan <- server.Serve(1, PatternMatching{
+				"/transport/play": Method(func(msg Message) error {
+					msgChan <- msg
+					return nil
+				}),
+			})
+		}(server)
+	}
 
-	// client, err := DialUDP("udp", nil, gaddr)
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
+	client, err := DialMulticast("udp4", nil, gaddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
 
-	// msg, err := NewMessage("/mcast/method")
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
+	if err := client.Send(Message{Address: "/transport/play"}); err != nil {
+		t.Fatal(err)
+	}
 
-	// if err := client.Send(msg); err != nil {
-	// 	t.Fatal(err)
-	// }
+	timeout := time.After(2 * time.Second)
 
-	// select {
-	// default:
-	// 	wg.Wait()
-	// case err := <-errChan:
-	// 	if err != nil {
-	// 		t.Fatal(err)
-	// 	}
-	// }
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgChan:
+			if expected, got := "/transport/play", msg.Address; expected != got {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("timeout waiting for multicast message")
+		}
+	}
 }
@@ -0,0 +1,102 @@
+package osc
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Mux is a dispatcher that routes messages to other dispatchers by address prefix,
+// like the subtree patterns of http.ServeMux, e.g.
+//
+//	Mux{
+//		"/mixer":     mixer,     // Gets /mixer/1/gain as /1/gain.
+//		"/transport": transport, // Gets /transport/play as /play.
+//	}
+//
+// A prefix matches a message if the first parts of the message's address match
+// the parts of the prefix, so messages whose addresses are patterns can be routed to more than one dispatcher.
+// If prefixes that are nested in each other match a concrete address, e.g. /mixer and /mixer/master
+// both match /mixer/master/gain, only the longest one is used.
+// A pattern is forwarded to all the prefixes it matches, e.g. /mixer/*/gain is forwarded
+// to /mixer as /*/gain and to /mixer/master as /gain.
+// The prefix is stripped from the address of the message before it is forwarded,
+// and a message to the prefix itself is forwarded to the root address "/".
+// Messages that no prefix matches are ignored.
+type Mux map[string]Dispatcher
+
+// Dispatch invokes an OSC bundle's messages.
+func (m Mux) Dispatch(b Bundle, exactMatch bool) error {
+	return m.DispatchContext(context.Background(), b, exactMatch)
+}
+
+// DispatchContext invokes an OSC bundle's messages, see PatternMatching.DispatchContext.
+// The bundle's timetag is handled by the mux, so the dispatchers it forwards messages to
+// get them when it is time.
+func (m Mux) DispatchContext(ctx context.Context, b Bundle, exactMatch bool) error {
+	return dispatchContext(ctx, b, func(msg Message) error {
+		return m.Invoke(msg, exactMatch)
+	})
+}
+
+// Invoke forwards a message to the dispatchers whose prefixes match it,
+// in the lexical order of the prefixes, and returns all the errors together.
+func (m Mux) Invoke(msg Message, exactMatch bool) error {
+	var (
+		errs     = []error{}
+		longest  = 0
+		matches  = map[string]int{} // Number of parts of each matching prefix.
+		parts    = msg.AddressSegments()
+		prefixes = m.prefixes()
+	)
+	for _, prefix := range prefixes {
+		n, matched, err := matchPrefix(parts, prefix, exactMatch)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		matches[prefix] = n
+		if n > longest {
+			longest = n
+		}
+	}
+	literal := exactMatch || countWildcards(msg.Address) == 0
+
+	for _, prefix := range prefixes {
+		n, ok := matches[prefix]
+		if !ok || (literal && n < longest) {
+			continue
+		}
+		forwarded := msg
+		forwarded.Address = string(MessageChar) + strings.Join(parts[n:], string(MessageChar))
+
+		if err := m[prefix].Invoke(forwarded, exactMatch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// prefixes returns the prefixes of the mux, sorted.
+func (m Mux) prefixes() []string {
+	prefixes := make([]string, 0, len(m))
+	for prefix := range m {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// matchPrefix returns the number of parts of prefix and whether they match the first parts of an address.
+func matchPrefix(parts []string, prefix string, exactMatch bool) (int, bool, error) {
+	prefixParts := (Message{Address: prefix}).AddressSegments()
+	if len(prefixParts) > len(parts) {
+		return len(prefixParts), false, nil
+	}
+	head := Message{Address: string(MessageChar) + strings.Join(parts[:len(prefixParts)], string(MessageChar))}
+
+	matched, err := head.Match(prefix, exactMatch)
+	return len(prefixParts), matched, err
+}
@@ -0,0 +1,100 @@
+package osc
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestMux(t *testing.T) {
+	fired := []string{}
+
+	record := func(name string) MessageHandler {
+		return Method(func(msg Message) error {
+			fired = append(fired, name+" "+msg.Address)
+			return nil
+		})
+	}
+	mux := Mux{
+		"/mixer": PatternMatching{
+			"/1/gain": record("mixer"),
+			"/2/gain": record("mixer"),
+		},
+		"/mixer/master": PatternMatching{
+			"/gain": record("master"),
+		},
+		"/transport": PatternMatching{
+			"/":     record("transport"),
+			"/play": record("transport"),
+		},
+	}
+	if err := checkDispatcher(mux, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, testcase := range []struct {
+		Packet   Packet
+		Expected []string
+	}{
+		{
+			Packet:   Message{Address: "/mixer/1/gain"},
+			Expected: []string{"mixer /1/gain"},
+		},
+		{
+			Packet:   Message{Address: "/transport/play"},
+			Expected: []string{"transport /play"},
+		},
+		{
+			Packet:   Message{Address: "/transport"},
+			Expected: []string{"transport /"},
+		},
+		{
+			Packet:   Message{Address: "/mixer/master/gain"},
+			Expected: []string{"master /gain"},
+		},
+		{
+			Packet:   Message{Address: "/mixer/*/gain"},
+			Expected: []string{"master /gain", "mixer /*/gain", "mixer /*/gain"},
+		},
+		{
+			Packet:   NewBundle(Immediately, Message{Address: "/mixer/2/gain"}, Message{Address: "/trans*/play"}),
+			Expected: []string{"mixer /2/gain", "transport /play"},
+		},
+		{
+			Packet:   Message{Address: "/lights/1"},
+			Expected: []string{},
+		},
+	} {
+		fired = []string{}
+
+		if err := DispatchPacket(mux, testcase.Packet, false); err != nil {
+			t.Fatalf("(testcase %d) %s", i, err)
+		}
+		sort.Strings(fired)
+
+		if expected, got := testcase.Expected, fired; !reflect.DeepEqual(expected, got) {
+			t.Fatalf("(testcase %d) expected %q, got %q", i, expected, got)
+		}
+	}
+}
+
+func TestMuxError(t *testing.T) {
+	mux := Mux{
+		"/a": PatternMatching{
+			"/b": Method(func(msg Message) error { return errors.New("oops") }),
+		},
+	}
+	if err := mux.Invoke(Message{Address: "/a/b"}, false); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := mux.Invoke(Message{Address: "/[a/b"}, false); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if err := checkDispatcher(Mux{"a": PatternMatching{}}, nil); errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %v", err)
+	}
+	if err := checkDispatcher(Mux{"/a": PatternMatching{"b": nil}}, nil); errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %v", err)
+	}
+}
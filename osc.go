@@ -5,7 +5,11 @@ import (
 	"context"
 	"encoding/binary"
 	"net"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -17,13 +21,16 @@ const (
 
 // Typetag constants.
 const (
-	TypetagPrefix byte = ','
-	TypetagInt    byte = 'i'
-	TypetagFloat  byte = 'f'
-	TypetagString byte = 's'
-	TypetagBlob   byte = 'b'
-	TypetagFalse  byte = 'F'
-	TypetagTrue   byte = 'T'
+	TypetagPrefix  byte = ','
+	TypetagInt     byte = 'i'
+	TypetagFloat   byte = 'f'
+	TypetagString  byte = 's'
+	TypetagBlob    byte = 'b'
+	TypetagFalse   byte = 'F'
+	TypetagTrue    byte = 'T'
+	TypetagNil     byte = 'N'
+	TypetagImpulse byte = 'I'
+	TypetagTimetag byte = 't'
 )
 
 var (
@@ -40,13 +47,24 @@ type Packet interface {
 	Equal(other Packet) bool
 }
 
+// PacketSender returns the address that a packet was received from,
+// or nil if the packet was not received from the network or is not a Message or a Bundle.
+// It makes it possible to record where a packet came from without a type switch.
+func PacketSender(p Packet) net.Addr {
+	switch x := p.(type) {
+	case Message:
+		return x.Sender
+	case Bundle:
+		return x.Sender
+	default:
+		return nil
+	}
+}
+
 // ToBytes returns an OSC representation of the given string.
-// This means that the returned byte slice is padded with null bytes
-// so that it's length is a multiple of 4.
+// This means that the returned byte slice is null-terminated and padded with null bytes
+// so that it's length is a multiple of 4, which is also true for the empty string.
 func ToBytes(s string) []byte {
-	if len(s) == 0 {
-		return []byte{}
-	}
 	return Pad(append([]byte(s), 0))
 }
 
@@ -81,6 +99,22 @@ func ReadString(data []byte) (string, int64) {
 	return string(bytes.TrimRight(data, "\x00")), int64(len(data))
 }
 
+// readPaddedString reads an OSC-string from a byte slice.
+// Unlike ReadString, it does not make up for missing bytes:
+// ErrParse is returned if data does not contain the null terminator
+// and all of the padding that follows it.
+func readPaddedString(data []byte) (string, int64, error) {
+	nullidx := bytes.IndexByte(data, 0)
+	if nullidx == -1 {
+		return "", 0, errors.Wrap(ErrParse, "unterminated string")
+	}
+	l := int64(nullidx+4) &^ 3
+	if l > int64(len(data)) {
+		return "", 0, errors.Wrap(ErrParse, "string is missing padding")
+	}
+	return string(data[:nullidx]), l, nil
+}
+
 // ReadBlob reads a blob of the given length from the given slice of bytes.
 func ReadBlob(length int32, data []byte) ([]byte, int64) {
 	l := length
@@ -97,10 +131,58 @@ func ReadBlob(length int32, data []byte) ([]byte, int64) {
 	return data[:idx], int64(idx)
 }
 
+// parsePacket parses an OSC message or bundle.
+func parsePacket(data []byte, sender net.Addr) (Packet, error) {
+	if len(data) == 0 {
+		return nil, errors.Wrap(ErrParse, "empty packet")
+	}
+	switch data[0] {
+	case BundleTag[0]:
+		return ParseBundle(data, sender)
+	case MessageChar:
+		return ParseMessage(data, sender)
+	default:
+		return nil, ErrParse
+	}
+}
+
+// ParsePackets parses a sequence of packets that are each prefixed
+// with their size as an int32, the framing used by stream transports.
+// It reads packets until data is exhausted and returns an error
+// if the last packet is incomplete.
+func ParsePackets(data []byte) ([]Packet, error) {
+	packets := []Packet{}
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.Wrapf(ErrParse, "packet %d: size needs 4 bytes, %d remaining", len(packets), len(data))
+		}
+		size := int64(int32(byteOrder.Uint32(data)))
+		if size < 0 || size > int64(len(data)-4) {
+			return nil, errors.Wrapf(ErrParse, "packet %d: size %d exceeds remaining data", len(packets), size)
+		}
+		p, err := parsePacket(data[4:4+size], nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "packet %d", len(packets))
+		}
+		packets = append(packets, p)
+		data = data[4+size:]
+	}
+	return packets, nil
+}
+
 // Incoming represents incoming data.
 type Incoming struct {
-	Data   []byte
-	Sender net.Addr
+	Data       []byte
+	Sender     net.Addr
+	ReceivedAt time.Time
+
+	// truncated is true if the data filled the whole read buffer,
+	// which means that the packet was probably cut short.
+	truncated bool
+
+	// release, if it is not nil, returns Data's buffer to the pool it came from.
+	// Data must not be used after calling it.
+	release func()
 }
 
 type netWriter interface {
@@ -108,47 +190,147 @@ type netWriter interface {
 	WriteTo([]byte, net.Addr) (int, error)
 }
 
-func checkDispatcher(dispatcher Dispatcher) error {
+// checkDispatcher returns an error if the dispatcher is nil or has handlers whose addresses are invalid.
+// If schema is not nil, addresses also have to match it.
+// Only the addresses of PatternMatching, FuncMatching, OrderedMatching and SpecificMatching dispatchers can be checked,
+// and the prefixes and sub-dispatchers of a Mux, and the dispatcher wrapped by a Cache.
+// Other Dispatcher implementations are accepted as they are.
+// The addresses of OrderedMatching and SpecificMatching may be patterns.
+// An empty PatternMatching is valid, it just doesn't match anything.
+func checkDispatcher(dispatcher Dispatcher, schema *regexp.Regexp) error {
 	if dispatcher == nil {
 		return ErrNilDispatcher
 	}
-	messageHandlers, ok := dispatcher.(PatternMatching)
-	if ok {
-		for addr := range messageHandlers {
+	switch d := dispatcher.(type) {
+	case PatternMatching:
+		for addr := range d {
 			if err := ValidateAddress(addr); err != nil {
 				return err
 			}
+			if err := checkAddressSchema(addr, schema); err != nil {
+				return err
+			}
+		}
+	case FuncMatching:
+		return checkDispatcher(d.PatternMatching, schema)
+	case *Cache:
+		return checkDispatcher(d.Dispatcher, schema)
+	case OrderedMatching:
+		for _, route := range d {
+			if err := ValidatePattern(route.Address); err != nil {
+				return err
+			}
+			if err := checkAddressSchema(route.Address, schema); err != nil {
+				return err
+			}
+		}
+	case SpecificMatching:
+		for addr := range d {
+			if err := ValidatePattern(addr); err != nil {
+				return err
+			}
+			if err := checkAddressSchema(addr, schema); err != nil {
+				return err
+			}
+		}
+	case Mux:
+		// The schema applies to whole addresses, not to the parts the sub-dispatchers see.
+		for prefix, sub := range d {
+			if err := ValidateAddress(prefix); err != nil {
+				return err
+			}
+			if err := checkDispatcher(sub, nil); err != nil {
+				return errors.Wrapf(err, "mux prefix %s", prefix)
+			}
 		}
 	}
 	return nil
 }
 
+// checkAddressSchema returns an error if schema is not nil and addr does not match it.
+func checkAddressSchema(addr string, schema *regexp.Regexp) error {
+	if schema == nil || schema.MatchString(addr) {
+		return nil
+	}
+	return errors.Wrapf(ErrInvalidAddress, "%s does not match address schema %s", addr, schema)
+}
+
 // readSender knows how to read bytes and return the net.Addr
 // of the sender of the bytes.
 type readSender interface {
 	CloseChan() <-chan struct{}
 	Context() context.Context
+	SetReadDeadline(time.Time) error
 	read([]byte) (int, net.Addr, error)
 }
 
-func serve(r readSender, numWorkers int, exactMatch bool, dispatcher Dispatcher) error {
-	if err := checkDispatcher(dispatcher); err != nil {
+// serveOptions holds the settings a conn passes to serve.
+type serveOptions struct {
+	addressSchema *regexp.Regexp
+	errorHandler  func(error)
+	errorReply    sendToer
+	exactMatch    bool
+	inFlight      *sync.WaitGroup
+	rateLimiter   *rateLimiter
+	readBufSize   int
+	senderFilter  func(net.Addr) bool
+	stats         *connStats
+
+	// readTimeout is how long a single read may block.
+	// It only makes sense for packet-oriented conns.
+	readTimeout time.Duration
+}
+
+// serve reads packets from r and hands them to numWorkers workers until
+// the conn is closed, the context is canceled, or an error occurs.
+// All the goroutines that serve starts have exited or are about to exit when it returns.
+func serve(r readSender, numWorkers int, dispatcher Dispatcher, opts serveOptions) error {
+	if err := checkDispatcher(dispatcher, opts.addressSchema); err != nil {
 		return err
 	}
+	// Clear any deadline left over from a previous call to serve.
+	if err := r.SetReadDeadline(time.Time{}); err != nil {
+		return errors.Wrap(err, "clear read deadline")
+	}
 	var (
-		errChan = make(chan error)
-		ready   = make(chan worker, numWorkers)
+		done        = make(chan struct{})
+		errChan     = make(chan error)
+		ready       = make(chan worker, numWorkers)
+		ctx, cancel = context.WithCancel(r.Context())
 	)
+	defer func() {
+		close(done)
+		cancel()
+
+		// Interrupt the read that workerLoop is blocked in.
+		_ = r.SetReadDeadline(time.Now()) // Best effort.
+	}()
+
 	for i := 0; i < numWorkers; i++ {
 		go worker{
+			Context:    ctx,
 			DataChan:   make(chan Incoming),
 			Dispatcher: dispatcher,
+			Done:       done,
 			ErrChan:    errChan,
 			Ready:      ready,
-			ExactMatch: exactMatch,
+			ExactMatch: opts.exactMatch,
+			ErrorReply: opts.errorReply,
+			InFlight:   opts.inFlight,
+
+			ErrorHandler: opts.errorHandler,
+			Stats:        opts.stats,
 		}.run()
 	}
-	go workerLoop(r, ready, errChan)
+	if opts.readBufSize <= 0 {
+		opts.readBufSize = bufSize
+	}
+	if opts.inFlight != nil {
+		// The read loop counts as in-flight work, which makes it safe for it
+		// to add the packets it reads while someone is waiting on inFlight.
+		opts.inFlight.Add(1)
+	}
+	go workerLoop(r, ready, errChan, done, opts)
 
 	// If the connection is closed or the context is canceled then stop serving.
 	select {
@@ -161,24 +343,132 @@ func serve(r readSender, numWorkers int, exactMatch bool, dispatcher Dispatcher)
 	return nil
 }
 
-func workerLoop(r readSender, ready chan worker, errChan chan error) {
+// workerLoop reads packets and hands each one to the next worker that is ready.
+// It exits when the done chan is closed.
+// If opts.readTimeout is greater than zero then reads time out after readTimeout
+// (or when the context's deadline passes, if that is sooner) so that
+// workerLoop notices promptly when it is supposed to stop.
+// Packets from senders that opts.senderFilter rejects, and if opts.rateLimiter is not nil
+// packets from senders that exceed the limit, are dropped before they reach a worker,
+// and reported to opts.errorHandler if there is one.
+func workerLoop(r readSender, ready chan worker, errChan chan error, done <-chan struct{}, opts serveOptions) {
+	var (
+		inFlight    = opts.inFlight
+		readBufSize = opts.readBufSize
+		readTimeout = opts.readTimeout
+	)
+	if inFlight != nil {
+		defer inFlight.Done()
+	}
+	// Read buffers are recycled once the worker is done with the packet, since
+	// allocating one for every packet creates a lot of garbage under load.
+	pool := &sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, readBufSize)
+			return &buf
+		},
+	}
 	for {
-		data := make([]byte, bufSize)
-		_, sender, err := r.read(data)
+		if readTimeout > 0 {
+			if err := r.SetReadDeadline(readDeadline(r.Context(), readTimeout)); err != nil {
+				select {
+				case errChan <- errors.Wrap(err, "set read deadline"):
+				case <-done:
+				}
+				return
+			}
+		}
+		var (
+			buf            = pool.Get().(*[]byte)
+			data           = *buf
+			n, sender, err = r.read(data)
+			receivedAt     = time.Now()
+			release        = func() { pool.Put(buf) }
+		)
+		if err != nil {
+			release()
+		}
+		if isTimeout(err) {
+			select {
+			case <-done:
+				return
+			default:
+				continue
+			}
+		}
 		if err != nil {
 			// Tried non-blocking select on closeChan right before ReadFromUDP
 			// but that didn't stop us from reading a closed connection. [briansorahan]
 			if strings.Contains(err.Error(), "use of closed network connection") {
 				return
 			}
-			errChan <- err
+			select {
+			case errChan <- err:
+			case <-done:
+			}
 			return
 		}
 
+		if opts.stats != nil {
+			atomic.AddUint64(&opts.stats.packetsReceived, 1)
+		}
+		if opts.senderFilter != nil && !opts.senderFilter(sender) {
+			release()
+			if opts.errorHandler != nil {
+				opts.errorHandler(errors.Wrapf(ErrSenderRejected, "drop packet from %s", sender))
+			}
+			continue
+		}
+		if opts.rateLimiter != nil && !opts.rateLimiter.allow(sender, receivedAt) {
+			release()
+			if opts.errorHandler != nil {
+				opts.errorHandler(errors.Wrapf(ErrRateLimited, "drop packet from %s", sender))
+			}
+			continue
+		}
+
 		// Get the next worker.
-		worker := <-ready
+		var worker worker
+		select {
+		case worker = <-ready:
+		case <-done:
+			release()
+			return
+		}
 
 		// Assign them the data we just read.
-		worker.DataChan <- Incoming{Data: data, Sender: sender}
+		if inFlight != nil {
+			inFlight.Add(1)
+		}
+		select {
+		case worker.DataChan <- Incoming{
+			Data:       data[:n],
+			Sender:     sender,
+			ReceivedAt: receivedAt,
+			truncated:  n == len(data),
+			release:    release,
+		}:
+		case <-done:
+			release()
+			if inFlight != nil {
+				inFlight.Done()
+			}
+			return
+		}
 	}
 }
+
+// readDeadline returns the deadline for the next read.
+func readDeadline(ctx context.Context, readTimeout time.Duration) time.Time {
+	deadline := time.Now().Add(readTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// isTimeout returns true if err is a timeout error.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
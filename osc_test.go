@@ -2,7 +2,12 @@ package osc
 
 import (
 	"bytes"
+	"context"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 func TestToBytes(t *testing.T) {
@@ -12,7 +17,7 @@ func TestToBytes(t *testing.T) {
 	}{
 		{
 			Input:    "",
-			Expected: []byte{},
+			Expected: []byte{0, 0, 0, 0},
 		},
 		{
 			Input:    "a",
@@ -153,3 +158,95 @@ func TestReadBlob(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePackets(t *testing.T) {
+	var (
+		buf     = &bytes.Buffer{}
+		packets = []Packet{
+			Message{Address: "/foo", Arguments: Arguments{Int(1)}},
+			Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/bar"}}},
+			Message{Address: "/baz", Arguments: Arguments{String("qux")}},
+		}
+	)
+	for _, p := range packets {
+		b := p.Bytes()
+		_, _ = buf.Write(Int(int32(len(b))).Bytes())
+		_, _ = buf.Write(b)
+	}
+	got, err := ParsePackets(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := len(packets), len(got); expected != got {
+		t.Fatalf("expected %d packets, got %d", expected, got)
+	}
+	for i, p := range packets {
+		if !p.Equal(got[i]) {
+			t.Fatalf("(packet %d) expected %+v, got %+v", i, p, got[i])
+		}
+	}
+	// A trailing partial packet is an error.
+	for _, data := range [][]byte{
+		append(buf.Bytes(), 0, 0),
+		buf.Bytes()[:buf.Len()-4],
+	} {
+		if _, err := ParsePackets(data); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+}
+
+// packetReader is a readSender that hands out the same packet
+// every time it receives on its reads chan.
+type packetReader struct {
+	closeChan chan struct{}
+	packet    []byte
+	reads     chan struct{}
+}
+
+func (r packetReader) CloseChan() <-chan struct{}      { return r.closeChan }
+func (r packetReader) Context() context.Context        { return context.Background() }
+func (r packetReader) SetReadDeadline(time.Time) error { return nil }
+
+func (r packetReader) read(data []byte) (int, net.Addr, error) {
+	select {
+	case <-r.reads:
+		return copy(data, r.packet), nil, nil
+	case <-r.closeChan:
+		return 0, nil, errors.New("use of closed network connection")
+	}
+}
+
+func BenchmarkServe(b *testing.B) {
+	var (
+		handled = make(chan struct{})
+		r       = packetReader{
+			closeChan: make(chan struct{}),
+			packet:    Message{Address: "/foo", Arguments: Arguments{Int(1), Blob([]byte{1, 2, 3})}}.Bytes(),
+			reads:     make(chan struct{}),
+		}
+		dispatcher = PatternMatching{
+			"/foo": Method(func(msg Message) error {
+				handled <- struct{}{}
+				return nil
+			}),
+		}
+		errChan = make(chan error, 1)
+	)
+	go func() {
+		errChan <- serve(r, 1, dispatcher, serveOptions{})
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.reads <- struct{}{}
+		<-handled
+	}
+	b.StopTimer()
+
+	close(r.closeChan)
+	if err := <-errChan; err != nil {
+		b.Fatal(err)
+	}
+}
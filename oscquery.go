@@ -0,0 +1,104 @@
+package osc
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TypedHandler is a MessageHandler that knows the type tags of the messages it expects.
+// AddressSpace includes the type tags in the description of the handler's address,
+// and PatternMatching does not invoke the handler with messages whose type tags differ.
+type TypedHandler interface {
+	MessageHandler
+
+	// Typetags returns the expected type tags, without the leading ',', e.g. "if".
+	Typetags() string
+}
+
+// typedHandler adds type tags to a MessageHandler.
+type typedHandler struct {
+	MessageHandler
+	typetags string
+}
+
+// Typetags returns the expected type tags.
+func (h typedHandler) Typetags() string {
+	return h.typetags
+}
+
+// WithTypetags returns a TypedHandler that handles messages with h
+// and expects messages with the provided type tags (the leading ',' is optional).
+func WithTypetags(typetags string, h MessageHandler) TypedHandler {
+	return typedHandler{
+		MessageHandler: h,
+		typetags:       strings.TrimPrefix(typetags, string(TypetagPrefix)),
+	}
+}
+
+// checkTypetags returns an error whose cause is ErrInvalidTypeTag
+// if the handler is a TypedHandler and the message does not have the type tags it expects.
+func checkTypetags(handler MessageHandler, msg Message) error {
+	th, ok := handler.(TypedHandler)
+	if !ok {
+		return nil
+	}
+	var (
+		expected = th.Typetags()
+		got      = msg.typetags(false)
+	)
+	got = got[:bytes.IndexByte(got, 0)]
+
+	if expected != string(got) {
+		return errors.Wrapf(ErrInvalidTypeTag, "%s: expected type tags %q, got %q", msg.Address, expected, got)
+	}
+	return nil
+}
+
+// AddressNode describes a node of an OSC address space in the style of OSCQuery.
+// Nodes that have a handler have a Type, which is empty if the handler's type tags are unknown,
+// and nodes that are containers have Contents, keyed by the next part of the address.
+type AddressNode struct {
+	FullPath string                  `json:"FULL_PATH"`
+	Type     string                  `json:"TYPE,omitempty"`
+	Contents map[string]*AddressNode `json:"CONTENTS,omitempty"`
+}
+
+// Addresses returns the addresses of the handlers, sorted.
+func (h PatternMatching) Addresses() []string {
+	addresses := make([]string, 0, len(h))
+	for address := range h {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// AddressSpace returns a tree that describes the addresses of the handlers,
+// e.g. so that a remote UI can find out what controls exist.
+// The tree is rooted at "/" and marshals to OSCQuery-style JSON.
+// Handlers that implement TypedHandler have their type tags in the tree.
+func (h PatternMatching) AddressSpace() *AddressNode {
+	root := &AddressNode{FullPath: string(MessageChar)}
+
+	for _, address := range h.Addresses() {
+		node := root
+		for _, part := range (Message{Address: address}).AddressSegments() {
+			if node.Contents == nil {
+				node.Contents = map[string]*AddressNode{}
+			}
+			child, ok := node.Contents[part]
+			if !ok {
+				child = &AddressNode{FullPath: strings.TrimSuffix(node.FullPath, string(MessageChar)) + string(MessageChar) + part}
+				node.Contents[part] = child
+			}
+			node = child
+		}
+		if th, ok := h[address].(TypedHandler); ok {
+			node.Type = th.Typetags()
+		}
+	}
+	return root
+}
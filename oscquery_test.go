@@ -0,0 +1,62 @@
+package osc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPatternMatchingAddressSpace(t *testing.T) {
+	noop := Method(func(msg Message) error { return nil })
+
+	h := PatternMatching{
+		"/synth/freq":     WithTypetags(",f", noop),
+		"/synth/gate":     WithTypetags("i", noop),
+		"/synth/note/on":  WithTypetags("ii", noop),
+		"/transport/stop": noop,
+	}
+	if expected, got := []string{"/synth/freq", "/synth/gate", "/synth/note/on", "/transport/stop"}, h.Addresses(); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	data, err := json.Marshal(h.AddressSpace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"FULL_PATH": "/",
+		"CONTENTS": {
+			"synth": {
+				"FULL_PATH": "/synth",
+				"CONTENTS": {
+					"freq": {"FULL_PATH": "/synth/freq", "TYPE": "f"},
+					"gate": {"FULL_PATH": "/synth/gate", "TYPE": "i"},
+					"note": {
+						"FULL_PATH": "/synth/note",
+						"CONTENTS": {
+							"on": {"FULL_PATH": "/synth/note/on", "TYPE": "ii"}
+						}
+					}
+				}
+			},
+			"transport": {
+				"FULL_PATH": "/transport",
+				"CONTENTS": {
+					"stop": {"FULL_PATH": "/transport/stop"}
+				}
+			}
+		}
+	}`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+	// Typed handlers still handle messages.
+	if err := h.Invoke(Message{Address: "/synth/freq", Arguments: Arguments{Float(440)}}, true); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package osctest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+// AssertBytes fails the test if p does not serialize to exactly want.
+// The failure message shows both byte slices side by side, one 4-byte
+// OSC word per row, with the rows that differ marked, which makes
+// padding and alignment mistakes easy to spot.
+func AssertBytes(t testing.TB, p osc.Packet, want []byte) {
+	t.Helper()
+
+	if got := p.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("serialized packet does not match:\n%s", diffBytes(got, want))
+	}
+}
+
+// diffBytes returns a side-by-side dump of got and want.
+func diffBytes(got, want []byte) string {
+	var (
+		b = &strings.Builder{}
+		n = len(got)
+	)
+	if len(want) > n {
+		n = len(want)
+	}
+	fmt.Fprintf(b, "got %d bytes, want %d bytes\n", len(got), len(want))
+	fmt.Fprintf(b, "  %-6s  %-8s  %-4s  %-8s  %-4s\n", "offset", "got", "", "want", "")
+
+	for off := 0; off < n; off += 4 {
+		var (
+			g    = word(got, off)
+			w    = word(want, off)
+			mark = " "
+		)
+		if !bytes.Equal(g, w) {
+			mark = "!"
+		}
+		fmt.Fprintf(b, "%s %06d  %-8x  %-4s  %-8x  %-4s\n", mark, off, g, printable(g), w, printable(w))
+	}
+	return b.String()
+}
+
+// word returns the (up to) 4 bytes of data at off.
+func word(data []byte, off int) []byte {
+	if off >= len(data) {
+		return nil
+	}
+	end := off + 4
+	if end > len(data) {
+		end = len(data)
+	}
+	return data[off:end]
+}
+
+// printable returns the bytes as text, with non-printable bytes replaced by '.'.
+func printable(data []byte) string {
+	s := make([]byte, len(data))
+	for i, c := range data {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		s[i] = c
+	}
+	return string(s)
+}
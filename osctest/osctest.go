@@ -0,0 +1,397 @@
+// Package osctest provides conformance checks for implementations of the
+// osc.Dispatcher and osc.Conn interfaces.
+//
+// The checks verify the parts of the OSC 1.0 spec that every implementation
+// has to get right: argument padding, type tag handling, bundle timing and
+// the pattern matching rules. Use them from a regular test:
+//
+//	func TestMyDispatcher(t *testing.T) {
+//	    osctest.RunDispatcher(t, func(handlers map[string]osc.MessageHandler) osc.Dispatcher {
+//	        return NewMyDispatcher(handlers)
+//	    })
+//	}
+package osctest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scgolang/osc"
+)
+
+// timeout is how long the checks wait for a packet to arrive.
+const timeout = 2 * time.Second
+
+// NewDispatcherFunc creates a dispatcher that invokes the provided handlers.
+// The keys of the map are the addresses the handlers are registered for.
+type NewDispatcherFunc func(handlers map[string]osc.MessageHandler) osc.Dispatcher
+
+// NewConnsFunc creates a pair of connected conns.
+// Packets sent with client must be received by server.
+// The checks close both conns when they are done with them.
+type NewConnsFunc func(t *testing.T) (server, client osc.Conn)
+
+// RunDispatcher runs the dispatcher conformance checks.
+func RunDispatcher(t *testing.T, newDispatcher NewDispatcherFunc) {
+	t.Run("PatternMatching", func(t *testing.T) { testPatternMatching(t, newDispatcher) })
+	t.Run("ExactMatch", func(t *testing.T) { testExactMatch(t, newDispatcher) })
+	t.Run("BundleTiming", func(t *testing.T) { testBundleTiming(t, newDispatcher) })
+	t.Run("NestedBundle", func(t *testing.T) { testNestedBundle(t, newDispatcher) })
+	t.Run("HandlerError", func(t *testing.T) { testHandlerError(t, newDispatcher) })
+}
+
+// RunConn runs the conn conformance checks.
+// The dispatcher that the server is served with is osc.PatternMatching.
+func RunConn(t *testing.T, newConns NewConnsFunc) {
+	t.Run("Padding", func(t *testing.T) { testPadding(t, newConns) })
+	t.Run("Typetags", func(t *testing.T) { testTypetags(t, newConns) })
+	t.Run("Bundle", func(t *testing.T) { testBundle(t, newConns) })
+	t.Run("PatternMatching", func(t *testing.T) { testConnPatternMatching(t, newConns) })
+}
+
+// recorder records the addresses of the handlers that get invoked.
+type recorder struct {
+	mu      sync.Mutex
+	invoked []string
+	times   []time.Time
+}
+
+// handler returns a handler that records address when it is invoked.
+func (r *recorder) handler(address string) osc.MessageHandler {
+	return osc.Method(func(msg osc.Message) error {
+		r.mu.Lock()
+		r.invoked = append(r.invoked, address)
+		r.times = append(r.times, time.Now())
+		r.mu.Unlock()
+		return nil
+	})
+}
+
+// handlers returns handlers for each of the provided addresses.
+func (r *recorder) handlers(addresses ...string) map[string]osc.MessageHandler {
+	handlers := map[string]osc.MessageHandler{}
+	for _, address := range addresses {
+		handlers[address] = r.handler(address)
+	}
+	return handlers
+}
+
+// reset returns the addresses of the handlers that have been invoked, sorted, and forgets them.
+func (r *recorder) reset() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invoked := r.invoked
+	sort.Strings(invoked)
+	r.invoked, r.times = nil, nil
+	return invoked
+}
+
+// equal returns true if the two slices contain the same strings.
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testPatternMatching(t *testing.T, newDispatcher NewDispatcherFunc) {
+	var (
+		r          = &recorder{}
+		dispatcher = newDispatcher(r.handlers("/synth/freq", "/synth/amp", "/fx/reverb"))
+	)
+	for _, testcase := range []struct {
+		Pattern  string
+		Expected []string
+	}{
+		{Pattern: "/synth/freq", Expected: []string{"/synth/freq"}},
+		{Pattern: "/synth/fr?q", Expected: []string{"/synth/freq"}},
+		{Pattern: "/synth/f*", Expected: []string{"/synth/freq"}},
+		{Pattern: "/synth/[f]req", Expected: []string{"/synth/freq"}},
+		{Pattern: "/synth/[a-e]mp", Expected: []string{"/synth/amp"}},
+		{Pattern: "/synth/[!f]*", Expected: []string{"/synth/amp"}},
+		{Pattern: "/synth/{freq,gain}", Expected: []string{"/synth/freq"}},
+		{Pattern: "/*/reverb", Expected: []string{"/fx/reverb"}},
+		{Pattern: "/synth/*", Expected: []string{"/synth/amp", "/synth/freq"}},
+		{Pattern: "/*/*", Expected: []string{"/fx/reverb", "/synth/amp", "/synth/freq"}},
+		{Pattern: "/synth", Expected: []string{}},
+		{Pattern: "/synth/freq/x", Expected: []string{}},
+		{Pattern: "/nope", Expected: []string{}},
+	} {
+		if err := dispatcher.Invoke(osc.Message{Address: testcase.Pattern}, false); err != nil {
+			t.Fatalf("(pattern %s) %s", testcase.Pattern, err)
+		}
+		if expected, got := testcase.Expected, r.reset(); !equal(expected, got) {
+			t.Fatalf("(pattern %s) expected %q to be invoked, got %q", testcase.Pattern, expected, got)
+		}
+	}
+}
+
+func testExactMatch(t *testing.T, newDispatcher NewDispatcherFunc) {
+	var (
+		r          = &recorder{}
+		dispatcher = newDispatcher(r.handlers("/synth/freq"))
+	)
+	for _, testcase := range []struct {
+		Address  string
+		Expected []string
+	}{
+		{Address: "/synth/freq", Expected: []string{"/synth/freq"}},
+		{Address: "/synth/f*", Expected: []string{}},
+		{Address: "/synth/fr?q", Expected: []string{}},
+	} {
+		if err := dispatcher.Invoke(osc.Message{Address: testcase.Address}, true); err != nil {
+			t.Fatalf("(address %s) %s", testcase.Address, err)
+		}
+		if expected, got := testcase.Expected, r.reset(); !equal(expected, got) {
+			t.Fatalf("(address %s) expected %q to be invoked, got %q", testcase.Address, expected, got)
+		}
+	}
+}
+
+func testBundleTiming(t *testing.T, newDispatcher NewDispatcherFunc) {
+	var (
+		r          = &recorder{}
+		dispatcher = newDispatcher(r.handlers("/synth/freq"))
+		delay      = 50 * time.Millisecond
+		when       = time.Now().Add(delay)
+	)
+	bundle := osc.Bundle{
+		Timetag: osc.FromTime(when),
+		Packets: []osc.Packet{osc.Message{Address: "/synth/freq"}},
+	}
+	if err := dispatcher.Dispatch(bundle, false); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	times := r.times
+	r.mu.Unlock()
+
+	if expected, got := 1, len(times); expected != got {
+		t.Fatalf("expected %d invocations, got %d", expected, got)
+	}
+	// Timetags have sub-nanosecond precision, but the conversion to a time.Time may be off by a little.
+	if early := when.Sub(times[0]); early > time.Millisecond {
+		t.Fatalf("bundle was invoked %s before its timetag", early)
+	}
+	r.reset()
+
+	// A bundle whose timetag is Immediately, or in the past, must be invoked right away.
+	for _, tt := range []osc.Timetag{osc.Immediately, osc.FromTime(time.Now().Add(-time.Hour))} {
+		start := time.Now()
+
+		bundle := osc.Bundle{
+			Timetag: tt,
+			Packets: []osc.Packet{osc.Message{Address: "/synth/freq"}},
+		}
+		if err := dispatcher.Dispatch(bundle, false); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > delay {
+			t.Fatalf("(timetag %s) expected bundle to be invoked immediately, took %s", tt, elapsed)
+		}
+		if expected, got := []string{"/synth/freq"}, r.reset(); !equal(expected, got) {
+			t.Fatalf("(timetag %s) expected %q to be invoked, got %q", tt, expected, got)
+		}
+	}
+}
+
+func testNestedBundle(t *testing.T, newDispatcher NewDispatcherFunc) {
+	var (
+		r          = &recorder{}
+		dispatcher = newDispatcher(r.handlers("/synth/freq", "/synth/amp", "/fx/reverb"))
+	)
+	bundle := osc.Bundle{
+		Timetag: osc.Immediately,
+		Packets: []osc.Packet{
+			osc.Message{Address: "/synth/freq"},
+			osc.Bundle{
+				Timetag: osc.Immediately,
+				Packets: []osc.Packet{
+					osc.Message{Address: "/synth/amp"},
+					osc.Message{Address: "/fx/reverb"},
+				},
+			},
+		},
+	}
+	if err := dispatcher.Dispatch(bundle, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []string{"/fx/reverb", "/synth/amp", "/synth/freq"}, r.reset(); !equal(expected, got) {
+		t.Fatalf("expected %q to be invoked, got %q", expected, got)
+	}
+}
+
+func testHandlerError(t *testing.T, newDispatcher NewDispatcherFunc) {
+	dispatcher := newDispatcher(map[string]osc.MessageHandler{
+		"/fail": osc.Method(func(msg osc.Message) error {
+			return osc.ErrInvalidAddress
+		}),
+	})
+	if err := dispatcher.Invoke(osc.Message{Address: "/fail"}, false); err == nil {
+		t.Fatal("expected the error returned by the handler to be returned from Invoke")
+	}
+	bundle := osc.Bundle{
+		Timetag: osc.Immediately,
+		Packets: []osc.Packet{osc.Message{Address: "/fail"}},
+	}
+	if err := dispatcher.Dispatch(bundle, false); err == nil {
+		t.Fatal("expected the error returned by the handler to be returned from Dispatch")
+	}
+}
+
+// received is a message that was received by the handler for address.
+type received struct {
+	address string
+	msg     osc.Message
+}
+
+// serve serves a new pair of conns and returns the client and a channel
+// that receives every message dispatched to the /osctest/a and /osctest/b handlers.
+func serve(t *testing.T, newConns NewConnsFunc) (osc.Conn, chan received) {
+	server, client := newConns(t)
+
+	var (
+		msgs       = make(chan received, 16)
+		dispatcher = osc.PatternMatching{}
+	)
+	for _, address := range []string{"/osctest/a", "/osctest/b"} {
+		address := address
+		dispatcher[address] = osc.Method(func(msg osc.Message) error {
+			msgs <- received{address: address, msg: msg}
+			return nil
+		})
+	}
+	errChan := make(chan error, 1)
+
+	go func() {
+		errChan <- server.Serve(1, dispatcher)
+	}()
+	t.Cleanup(func() {
+		_ = client.Close() // Best effort.
+		_ = server.Close() // Best effort.
+
+		select {
+		case <-errChan:
+		case <-time.After(timeout):
+			t.Error("timeout waiting for Serve to return after closing the server")
+		}
+	})
+	return client, msgs
+}
+
+// receive waits for a message.
+func receive(t *testing.T, msgs chan received) received {
+	t.Helper()
+
+	select {
+	case r := <-msgs:
+		return r
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting for message")
+	}
+	return received{}
+}
+
+func testPadding(t *testing.T, newConns NewConnsFunc) {
+	client, msgs := serve(t, newConns)
+
+	for n := 0; n <= 8; n++ {
+		blob := make([]byte, n)
+		for i := range blob {
+			blob[i] = 'a' + byte(i)
+		}
+		s := string(blob)
+
+		msg := osc.Message{
+			Address:   "/osctest/a",
+			Arguments: osc.Arguments{osc.String(s), osc.Blob(blob), osc.Int(int32(n))},
+		}
+		if got := len(msg.Bytes()); got%4 != 0 {
+			t.Fatalf("(length %d) expected message size to be a multiple of 4, got %d", n, got)
+		}
+		if err := client.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		if got := receive(t, msgs).msg; !msg.Equal(got) {
+			t.Fatalf("(length %d) expected %+v, got %+v", n, msg, got)
+		}
+	}
+}
+
+func testTypetags(t *testing.T, newConns NewConnsFunc) {
+	client, msgs := serve(t, newConns)
+
+	msg := osc.Message{
+		Address: "/osctest/a",
+		Arguments: osc.Arguments{
+			osc.Int(-1),
+			osc.Float(3.25),
+			osc.Bool(true),
+			osc.Bool(false),
+			osc.String("foo"),
+			osc.Blob([]byte{1, 2, 3}),
+			osc.Nil{},
+			osc.Impulse{},
+			osc.FromTime(time.Date(2017, time.March, 4, 12, 30, 15, 0, time.UTC)),
+		},
+	}
+	if err := client.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	got := receive(t, msgs).msg
+	if expected, got := string(msg.Typetags()), string(got.Typetags()); expected != got {
+		t.Fatalf("expected type tags %s, got %s", expected, got)
+	}
+	if !msg.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", msg, got)
+	}
+}
+
+func testBundle(t *testing.T, newConns NewConnsFunc) {
+	client, msgs := serve(t, newConns)
+
+	bundle := osc.Bundle{
+		Timetag: osc.Immediately,
+		Packets: []osc.Packet{
+			osc.Message{Address: "/osctest/a", Arguments: osc.Arguments{osc.Int(1)}},
+			osc.Bundle{
+				Timetag: osc.Immediately,
+				Packets: []osc.Packet{
+					osc.Message{Address: "/osctest/b", Arguments: osc.Arguments{osc.Int(2)}},
+				},
+			},
+		},
+	}
+	if err := client.Send(bundle); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{receive(t, msgs).msg.Address, receive(t, msgs).msg.Address}
+	sort.Strings(got)
+
+	if expected := []string{"/osctest/a", "/osctest/b"}; !equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func testConnPatternMatching(t *testing.T, newConns NewConnsFunc) {
+	client, msgs := serve(t, newConns)
+
+	if err := client.Send(osc.Message{Address: "/osctest/[b-z]"}); err != nil {
+		t.Fatal(err)
+	}
+	r := receive(t, msgs)
+	if expected, got := "/osctest/b", r.address; expected != got {
+		t.Fatalf("expected message to be dispatched to %s, got %s", expected, got)
+	}
+	if expected, got := "/osctest/[b-z]", r.msg.Address; expected != got {
+		t.Fatalf("expected address %s, got %s", expected, got)
+	}
+}
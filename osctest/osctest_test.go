@@ -0,0 +1,69 @@
+package osctest
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/scgolang/osc"
+)
+
+func TestPatternMatching(t *testing.T) {
+	RunDispatcher(t, func(handlers map[string]osc.MessageHandler) osc.Dispatcher {
+		return osc.PatternMatching(handlers)
+	})
+}
+
+func TestUDPConn(t *testing.T) {
+	RunConn(t, func(t *testing.T) (osc.Conn, osc.Conn) {
+		laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, err := osc.ListenUDP("udp", laddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raddr, err := net.ResolveUDPAddr("udp", server.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := osc.DialUDP("udp", nil, raddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return server, client
+	})
+}
+
+func TestAssertBytes(t *testing.T) {
+	msg := osc.Message{
+		Address:   "/foo",
+		Arguments: osc.Arguments{osc.Int(1), osc.String("bar")},
+	}
+	AssertBytes(t, msg, []byte{
+		'/', 'f', 'o', 'o', 0, 0, 0, 0,
+		',', 'i', 's', 0,
+		0, 0, 0, 1,
+		'b', 'a', 'r', 0,
+	})
+}
+
+func TestDiffBytes(t *testing.T) {
+	var (
+		got  = []byte{'/', 'f', 'o', 'o', ',', 'i', 0, 0, 0, 0, 0, 1}
+		want = []byte{'/', 'f', 'o', 'o', 0, 0, 0, 0, ',', 'i', 0, 0, 0, 0, 0, 1}
+	)
+	expected := strings.Join([]string{
+		"got 12 bytes, want 16 bytes",
+		"  offset  got             want          ",
+		"  000000  2f666f6f  /foo  2f666f6f  /foo",
+		"! 000004  2c690000  ,i..  00000000  ....",
+		"! 000008  00000001  ....  2c690000  ,i..",
+		"! 000012                  00000001  ....",
+		"",
+	}, "\n")
+	if got := diffBytes(got, want); expected != got {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
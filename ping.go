@@ -0,0 +1,58 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Addresses used by Ping.
+const (
+	PingAddress = "/ping"
+	PongAddress = "/pong"
+)
+
+// pingToken is used to tell the replies to different pings apart.
+var pingToken int32
+
+// Ping measures the round-trip time to the OSC responder at addr, which is a "host:port" string.
+// It sends a message to PingAddress whose only argument is an integer token,
+// and waits for the responder to reply with a message to PongAddress
+// whose first argument is the same token.
+// An error is returned if the reply does not arrive within timeout.
+func Ping(addr string, timeout time.Duration) (time.Duration, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return 0, errors.Wrap(err, "resolve address")
+	}
+	conn, err := DialUDPContext(context.Background(), "udp", nil, raddr)
+	if err != nil {
+		return 0, errors.Wrap(err, "dial")
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	var (
+		token = Int(atomic.AddInt32(&pingToken, 1))
+		start = time.Now()
+	)
+	if err := conn.SetReadDeadline(start.Add(timeout)); err != nil {
+		return 0, errors.Wrap(err, "set read deadline")
+	}
+	if err := conn.Send(Message{Address: PingAddress, Arguments: Arguments{token}}); err != nil {
+		return 0, errors.Wrap(err, "send ping")
+	}
+	for {
+		p, _, err := conn.ReadPacket()
+		if err != nil {
+			return 0, errors.Wrap(err, "wait for pong")
+		}
+		msg, ok := p.(Message)
+		if !ok || msg.Address != PongAddress || len(msg.Arguments) == 0 || !msg.Arguments[0].Equal(token) {
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
@@ -0,0 +1,62 @@
+package osc
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = responder.Close() }() // Best effort.
+
+	const delay = 10 * time.Millisecond
+
+	go func() {
+		_ = responder.Serve(1, PatternMatching{
+			PingAddress: Method(func(msg Message) error {
+				time.Sleep(delay)
+
+				// Send something unrelated first, Ping should ignore it.
+				if err := responder.SendTo(msg.Sender, Message{Address: "/status"}); err != nil {
+					return err
+				}
+				return responder.SendTo(msg.Sender, Message{
+					Address:   PongAddress,
+					Arguments: msg.Arguments,
+				})
+			}),
+		})
+	}()
+	rtt, err := Ping(responder.LocalAddr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtt < delay {
+		t.Fatalf("expected round-trip time of at least %s, got %s", delay, rtt)
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing ever replies.
+	silent, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = silent.Close() }() // Best effort.
+
+	if _, err := Ping(silent.LocalAddr().String(), 20*time.Millisecond); !isTimeout(err) {
+		t.Fatalf("expected timeout, got %+v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package osc
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Senders come from the network, so a flood must not use unbounded memory.
+const (
+	// maxRateSamples limits the number of timestamps SenderRates keeps per sender.
+	// The buffers start small and only grow this large for senders that send that much.
+	maxRateSamples = 1024
+
+	// minRateSamples is the initial size of a sender's buffer.
+	minRateSamples = 4
+
+	// maxIdleSenders is the number of senders SenderRates tracks before
+	// it forgets the ones that have not sent anything within the window.
+	maxIdleSenders = 1024
+)
+
+// SenderRates estimates how many messages per second each sender is currently sending,
+// e.g. to detect floods. The rate is measured over a sliding window.
+// It is safe for concurrent use.
+type SenderRates struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	senders map[string]*timestamps
+}
+
+// NewSenderRates creates a SenderRates that measures rates over the provided window.
+// If window is not positive then a window of one second is used.
+func NewSenderRates(window time.Duration) *SenderRates {
+	if window <= 0 {
+		window = time.Second
+	}
+	return &SenderRates{
+		window:  window,
+		now:     time.Now,
+		senders: map[string]*timestamps{},
+	}
+}
+
+// Middleware returns a middleware that counts the messages the handler gets from each sender.
+// Messages that do not have a sender are not counted.
+func (r *SenderRates) Middleware() Middleware {
+	return func(handler MessageHandler) MessageHandler {
+		return Method(func(msg Message) error {
+			if msg.Sender != nil {
+				r.observe(msg.Sender.String(), r.now())
+			}
+			return handler.Handle(msg)
+		})
+	}
+}
+
+// SenderRate returns the current rate, in messages per second, of the provided sender.
+// It returns 0 for senders that have not sent anything within the window.
+func (r *SenderRates) SenderRate(addr net.Addr) float64 {
+	if addr == nil {
+		return 0
+	}
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ts, ok := r.senders[addr.String()]
+	if !ok {
+		return 0
+	}
+	ts.expire(now.Add(-r.window))
+
+	if ts.n == 0 {
+		delete(r.senders, addr.String())
+		return 0
+	}
+	// If the buffer is at its maximum size the window holds more messages than we keep,
+	// so the rate is measured over the time the buffer covers instead.
+	span := r.window
+	if ts.n == maxRateSamples {
+		span = now.Sub(ts.oldest())
+	}
+	if span <= 0 {
+		return 0
+	}
+	return float64(ts.n) / span.Seconds()
+}
+
+// observe records a message from sender at time t.
+func (r *SenderRates) observe(sender string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ts, ok := r.senders[sender]
+	if !ok {
+		if len(r.senders) >= maxIdleSenders {
+			r.forgetIdle(t)
+		}
+		ts = &timestamps{buf: make([]time.Time, minRateSamples)}
+		r.senders[sender] = ts
+	}
+	ts.expire(t.Add(-r.window))
+	ts.push(t)
+}
+
+// forgetIdle removes the senders that have not sent anything within the window before t.
+// The caller must hold r.mu.
+func (r *SenderRates) forgetIdle(t time.Time) {
+	cutoff := t.Add(-r.window)
+	for sender, ts := range r.senders {
+		if ts.expire(cutoff); ts.n == 0 {
+			delete(r.senders, sender)
+		}
+	}
+}
+
+// timestamps is a ring buffer of times, oldest first.
+// It grows as needed, up to maxRateSamples times.
+type timestamps struct {
+	buf   []time.Time
+	start int
+	n     int
+}
+
+// oldest returns the oldest time in the buffer.
+func (ts *timestamps) oldest() time.Time {
+	return ts.buf[ts.start]
+}
+
+// push adds a time to the buffer, growing the buffer if it is full,
+// or overwriting the oldest time if it has already reached its maximum size.
+func (ts *timestamps) push(t time.Time) {
+	if ts.n == len(ts.buf) && len(ts.buf) < maxRateSamples {
+		ts.grow()
+	}
+	if ts.n == len(ts.buf) {
+		ts.buf[ts.start] = t
+		ts.start = (ts.start + 1) % len(ts.buf)
+		return
+	}
+	ts.buf[(ts.start+ts.n)%len(ts.buf)] = t
+	ts.n++
+}
+
+// grow doubles the size of the buffer, up to maxRateSamples.
+func (ts *timestamps) grow() {
+	size := 2 * len(ts.buf)
+	if size > maxRateSamples {
+		size = maxRateSamples
+	}
+	buf := make([]time.Time, size)
+	for i := 0; i < ts.n; i++ {
+		buf[i] = ts.buf[(ts.start+i)%len(ts.buf)]
+	}
+	ts.buf, ts.start = buf, 0
+}
+
+// expire removes the times that are not after cutoff.
+func (ts *timestamps) expire(cutoff time.Time) {
+	for ts.n > 0 && !ts.oldest().After(cutoff) {
+		ts.start = (ts.start + 1) % len(ts.buf)
+		ts.n--
+	}
+}
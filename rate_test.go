@@ -0,0 +1,82 @@
+package osc
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSenderRates(t *testing.T) {
+	var (
+		now    = time.Now()
+		r      = NewSenderRates(time.Second)
+		sender = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 57120}
+		other  = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 57121}
+	)
+	r.now = func() time.Time { return now }
+
+	h := r.Middleware()(Method(func(msg Message) error { return nil }))
+
+	send := func(rate int, d time.Duration) {
+		interval := time.Second / time.Duration(rate)
+		for elapsed := time.Duration(0); elapsed < d; elapsed += interval {
+			now = now.Add(interval)
+			if err := h.Handle(Message{Address: "/foo", Sender: sender}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, rate := range []int{
+		50,
+		200,
+		5000, // More messages than maxRateSamples in the window.
+		10,
+	} {
+		send(rate, 3*time.Second)
+
+		expected := float64(rate)
+		if got := r.SenderRate(sender); math.Abs(got-expected) > expected*0.05 {
+			t.Fatalf("expected a rate near %f, got %f", expected, got)
+		}
+	}
+	if expected, got := float64(0), r.SenderRate(other); expected != got {
+		t.Fatalf("expected %f, got %f", expected, got)
+	}
+	now = now.Add(2 * time.Second)
+
+	if expected, got := float64(0), r.SenderRate(sender); expected != got {
+		t.Fatalf("expected %f after the sender went quiet, got %f", expected, got)
+	}
+}
+
+func TestSenderRatesBoundedMemory(t *testing.T) {
+	var (
+		now = time.Now()
+		r   = NewSenderRates(time.Second)
+	)
+	r.now = func() time.Time { return now }
+
+	h := r.Middleware()(Method(func(msg Message) error { return nil }))
+
+	// A flood from many source ports, each of which sends once.
+	for port := 0; port < 10*maxIdleSenders; port++ {
+		now = now.Add(time.Millisecond)
+		sender := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+		if err := h.Handle(Message{Address: "/foo", Sender: sender}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Idle senders are forgotten once there are too many of them.
+	if max, got := maxIdleSenders+1, len(r.senders); got > max {
+		t.Fatalf("expected at most %d senders, got %d", max, got)
+	}
+	for sender, ts := range r.senders {
+		if expected, got := minRateSamples, len(ts.buf); expected != got {
+			t.Fatalf("(%s) expected a buffer of %d, got %d", sender, expected, got)
+		}
+	}
+}
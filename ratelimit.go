@@ -0,0 +1,106 @@
+package osc
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrRateLimited = errors.New("sender exceeded rate limit")
+)
+
+// maxIdleBuckets is the number of senders rateLimiter tracks before
+// it forgets the ones whose buckets have filled up again.
+const maxIdleBuckets = 1024
+
+// rateLimiter is a token bucket rate limiter with one bucket per sender IP.
+// It is safe for concurrent use.
+type rateLimiter struct {
+	rate  float64 // Tokens per second.
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket holds the tokens of a single sender.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a rate limiter that allows rate packets per second
+// from each sender, with bursts of up to burst packets.
+// If burst is less than 1 then a burst of 1 is used.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// allow returns true if a packet that sender sent at time now is within the limit.
+func (l *rateLimiter) allow(sender net.Addr, now time.Time) bool {
+	key := senderKey(sender)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.forgetFull(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens that accumulated since the bucket was last refilled.
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+}
+
+// forgetFull removes the buckets that are full,
+// since a new bucket for the same sender would be the same.
+func (l *rateLimiter) forgetFull(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// senderKey returns the key of a sender's bucket, which is its IP if it has one.
+func senderKey(sender net.Addr) string {
+	switch addr := sender.(type) {
+	case *net.UDPAddr:
+		return addr.IP.String()
+	case nil:
+		return ""
+	default:
+		return addr.String()
+	}
+}
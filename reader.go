@@ -0,0 +1,358 @@
+package osc
+
+import (
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArgumentReader reads the arguments of a message one at a time, in order.
+// A read only advances the reader if it succeeds.
+type ArgumentReader struct {
+	args Arguments
+	idx  int
+}
+
+// Reader returns an ArgumentReader positioned at the message's first argument.
+// Reading does not modify the message.
+func (msg Message) Reader() *ArgumentReader {
+	return &ArgumentReader{args: msg.Arguments}
+}
+
+// ArgResult is an argument sent on the chan returned by ArgsChan.
+// Value is an int32, float32, bool, string or []byte,
+// nil for Nil arguments, or the argument itself for Impulse and Timetag arguments.
+type ArgResult struct {
+	Type  byte
+	Value interface{}
+	Err   error
+}
+
+// ArgsChan returns a chan that receives each of the message's arguments, in order,
+// and is closed after the last one. If an argument has an unsupported type
+// the chan receives a result whose Err is set and then it is closed.
+// The chan is buffered, so it is fine to stop receiving from it early.
+func (msg Message) ArgsChan() <-chan ArgResult {
+	results := make(chan ArgResult, len(msg.Arguments))
+	defer close(results)
+
+	for _, a := range msg.Arguments {
+		result := ArgResult{Type: a.Typetag()}
+
+		switch x := a.(type) {
+		case Int:
+			result.Value = int32(x)
+		case Float:
+			result.Value = float32(x)
+		case Bool:
+			result.Value = bool(x)
+		case String:
+			result.Value = string(x)
+		case Blob:
+			result.Value = []byte(x)
+		case Nil:
+		case Impulse, Timetag:
+			result.Value = x
+		default:
+			result.Err = errors.Wrapf(ErrUnsupportedType, "%T", a)
+		}
+		results <- result
+
+		if result.Err != nil {
+			break
+		}
+	}
+	return results
+}
+
+// Len returns the number of arguments that have not been read.
+func (r *ArgumentReader) Len() int {
+	return len(r.args) - r.idx
+}
+
+// ReadInt32 reads the next argument as a 32-bit integer.
+func (r *ArgumentReader) ReadInt32() (int32, error) {
+	a, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	i, err := a.ReadInt32()
+	if err != nil {
+		return 0, err
+	}
+	r.idx++
+	return i, nil
+}
+
+// ReadFloat32 reads the next argument as a 32-bit float.
+func (r *ArgumentReader) ReadFloat32() (float32, error) {
+	a, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	f, err := a.ReadFloat32()
+	if err != nil {
+		return 0, err
+	}
+	r.idx++
+	return f, nil
+}
+
+// ReadBool reads the next argument as a boolean.
+func (r *ArgumentReader) ReadBool() (bool, error) {
+	a, err := r.next()
+	if err != nil {
+		return false, err
+	}
+	b, err := a.ReadBool()
+	if err != nil {
+		return false, err
+	}
+	r.idx++
+	return b, nil
+}
+
+// ReadString reads the next argument as a string.
+func (r *ArgumentReader) ReadString() (string, error) {
+	a, err := r.next()
+	if err != nil {
+		return "", err
+	}
+	s, err := a.ReadString()
+	if err != nil {
+		return "", err
+	}
+	r.idx++
+	return s, nil
+}
+
+// ReadBlob reads the next argument as a slice of bytes.
+func (r *ArgumentReader) ReadBlob() ([]byte, error) {
+	a, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.ReadBlob()
+	if err != nil {
+		return nil, err
+	}
+	r.idx++
+	return b, nil
+}
+
+// ReadTime reads the next argument as a time.
+// The argument has to be a timetag, which is converted with Timetag.Time,
+// so the "immediately" timetag is read as the zero time (see time.Time.IsZero).
+func (r *ArgumentReader) ReadTime() (time.Time, error) {
+	a, err := r.next()
+	if err != nil {
+		return time.Time{}, err
+	}
+	tt, ok := a.(Timetag)
+	if !ok {
+		return time.Time{}, errors.Wrapf(ErrInvalidTypeTag, "expected %c, got %c", TypetagTimetag, a.Typetag())
+	}
+	r.idx++
+	return tt.Time(), nil
+}
+
+// ReadAsString reads the next argument, whatever its type, and returns it as text.
+// Numbers are formatted in decimal, strings are returned as-is,
+// blobs are hex-encoded and bools are returned as true or false.
+// Nil and Impulse arguments are returned as nil and impulse,
+// and timetags are formatted as RFC 3339 times.
+func (r *ArgumentReader) ReadAsString() (string, error) {
+	a, err := r.next()
+	if err != nil {
+		return "", err
+	}
+	var s string
+
+	switch x := a.(type) {
+	case Int:
+		s = strconv.FormatInt(int64(x), 10)
+	case Float:
+		s = strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case String:
+		s = string(x)
+	case Blob:
+		s = hex.EncodeToString([]byte(x))
+	case Bool:
+		s = strconv.FormatBool(bool(x))
+	case Nil:
+		s = "nil"
+	case Impulse:
+		s = "impulse"
+	case Timetag:
+		s = x.String()
+	default:
+		return "", errors.Wrapf(ErrUnsupportedType, "read %T as string", a)
+	}
+	r.idx++
+	return s, nil
+}
+
+// BytesConsumed returns the number of argument bytes the reads so far have consumed,
+// i.e. the size of the binary encoding of the arguments that have been read or skipped,
+// including the size prefix and the padding of blobs and strings.
+func (r *ArgumentReader) BytesConsumed() int64 {
+	var n int64
+	for _, a := range r.args[:r.idx] {
+		n += int64(len(a.Bytes()))
+	}
+	return n
+}
+
+// Skip advances the reader past the next n arguments, whatever their types.
+// If there are fewer than n arguments left the reader does not move
+// and ErrIndexOutOfBounds is returned.
+func (r *ArgumentReader) Skip(n int) error {
+	if n < 0 || n > r.Len() {
+		return ErrIndexOutOfBounds
+	}
+	r.idx += n
+	return nil
+}
+
+// Savepoint captures the position of the reader and returns
+// a func that rolls the reader back to that position.
+// This makes it possible to speculatively read arguments
+// and undo the reads if they turn out to be the wrong shape.
+func (r *ArgumentReader) Savepoint() func() {
+	idx := r.idx
+	return func() {
+		r.idx = idx
+	}
+}
+
+// next returns the next argument without advancing the reader.
+func (r *ArgumentReader) next() (Argument, error) {
+	if r.idx >= len(r.args) {
+		return nil, ErrIndexOutOfBounds
+	}
+	return r.args[r.idx], nil
+}
+
+// ArgIterator iterates over the arguments of a message.
+//
+//	it := msg.Args()
+//	for it.Next() {
+//		switch it.Type() {
+//		case TypetagInt:
+//			i := it.Int32()
+//		...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// The typed accessors return the current argument, or the zero value if the current
+// argument has a different type, in which case Err returns an error and Next returns false.
+type ArgIterator struct {
+	args Arguments
+	idx  int
+	cur  Argument
+	err  error
+}
+
+// Args returns an iterator over a copy of the message's arguments.
+func (msg Message) Args() *ArgIterator {
+	return &ArgIterator{args: msg.clone().Arguments, idx: -1}
+}
+
+// Next advances the iterator to the next argument.
+// It returns false when there are no more arguments or an accessor has failed.
+func (it *ArgIterator) Next() bool {
+	if it.err != nil || it.idx >= len(it.args) {
+		return false
+	}
+	it.idx++
+	if it.idx >= len(it.args) {
+		it.cur = nil
+		return false
+	}
+	it.cur = it.args[it.idx]
+	return true
+}
+
+// Type returns the type tag of the current argument, or 0 if there is no current argument.
+func (it *ArgIterator) Type() byte {
+	if it.cur == nil {
+		return 0
+	}
+	return it.cur.Typetag()
+}
+
+// Err returns the first error encountered by the iterator.
+func (it *ArgIterator) Err() error {
+	return it.err
+}
+
+// Int32 returns the current argument as a 32-bit integer.
+func (it *ArgIterator) Int32() int32 {
+	if a := it.current(); a != nil {
+		i, err := a.ReadInt32()
+		it.fail(err)
+		return i
+	}
+	return 0
+}
+
+// Float32 returns the current argument as a 32-bit float.
+func (it *ArgIterator) Float32() float32 {
+	if a := it.current(); a != nil {
+		f, err := a.ReadFloat32()
+		it.fail(err)
+		return f
+	}
+	return 0
+}
+
+// Bool returns the current argument as a boolean.
+func (it *ArgIterator) Bool() bool {
+	if a := it.current(); a != nil {
+		b, err := a.ReadBool()
+		it.fail(err)
+		return b
+	}
+	return false
+}
+
+// String returns the current argument as a string.
+func (it *ArgIterator) String() string {
+	if a := it.current(); a != nil {
+		s, err := a.ReadString()
+		it.fail(err)
+		return s
+	}
+	return ""
+}
+
+// Blob returns the current argument as a slice of bytes.
+func (it *ArgIterator) Blob() []byte {
+	if a := it.current(); a != nil {
+		b, err := a.ReadBlob()
+		it.fail(err)
+		return b
+	}
+	return nil
+}
+
+// current returns the current argument.
+// It records ErrIndexOutOfBounds if there isn't one.
+func (it *ArgIterator) current() Argument {
+	if it.cur == nil {
+		it.fail(ErrIndexOutOfBounds)
+	}
+	return it.cur
+}
+
+// fail records the first error, along with the index of the argument it happened at.
+func (it *ArgIterator) fail(err error) {
+	if err != nil && it.err == nil {
+		it.err = errors.Wrapf(err, "argument %d", it.idx)
+	}
+}
@@ -0,0 +1,309 @@
+package osc
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestArgumentReader(t *testing.T) {
+	r := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Int(1), Float(2), Bool(true), String("bar"), Blob([]byte{3})},
+	}.Reader()
+
+	if expected, got := 5, r.Len(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	// A failed read does not advance the reader.
+	if _, err := r.ReadString(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	i, err := r.ReadInt32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int32(1), i; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	f, err := r.ReadFloat32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := float32(2), f; expected != got {
+		t.Fatalf("expected %f, got %f", expected, got)
+	}
+	b, err := r.ReadBool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := true, b; expected != got {
+		t.Fatalf("expected %t, got %t", expected, got)
+	}
+	s, err := r.ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "bar", s; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	blob, err := r.ReadBlob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []byte{3}, blob; !bytes.Equal(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if expected, got := 0, r.Len(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if _, err := r.ReadInt32(); err != ErrIndexOutOfBounds {
+		t.Fatalf("expected ErrIndexOutOfBounds, got %+v", err)
+	}
+}
+
+func TestArgumentReaderSavepoint(t *testing.T) {
+	r := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Int(1), String("bar")},
+	}.Reader()
+
+	rollback := r.Savepoint()
+
+	// Speculatively read an int followed by a float.
+	if _, err := r.ReadInt32(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadFloat32(); err != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %+v", err)
+	}
+	// Wrong shape, so undo and read the same int again.
+	rollback()
+
+	i, err := r.ReadInt32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int32(1), i; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	s, err := r.ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := "bar", s; expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestArgumentReaderReadAsString(t *testing.T) {
+	r := Message{
+		Address: "/foo",
+		Arguments: Arguments{
+			Int(-3),
+			Float(0.5),
+			String("bar"),
+			Blob{0xde, 0xad},
+			Bool(true),
+			Bool(false),
+		},
+	}.Reader()
+
+	got := []string{}
+	for r.Len() > 0 {
+		s, err := r.ReadAsString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	if expected := []string{"-3", "0.5", "bar", "dead", "true", "false"}; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if _, err := r.ReadAsString(); err != ErrIndexOutOfBounds {
+		t.Fatalf("expected ErrIndexOutOfBounds, got %+v", err)
+	}
+}
+
+func TestArgumentReaderSkip(t *testing.T) {
+	r := Message{
+		Address:   "/foo",
+		Arguments: Arguments{String("bar"), Blob{1, 2, 3}, Int(7)},
+	}.Reader()
+
+	if err := r.Skip(4); err != ErrIndexOutOfBounds {
+		t.Fatalf("expected ErrIndexOutOfBounds, got %+v", err)
+	}
+	if err := r.Skip(2); err != nil {
+		t.Fatal(err)
+	}
+	i, err := r.ReadInt32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int32(7), i; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	if err := r.Skip(1); err != ErrIndexOutOfBounds {
+		t.Fatalf("expected ErrIndexOutOfBounds, got %+v", err)
+	}
+}
+
+func TestArgumentReaderBytesConsumed(t *testing.T) {
+	msg := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Blob{1, 2, 3, 4, 5}, Int(7), Bool(true), String("bar")},
+	}
+	r := msg.Reader()
+
+	for i, expected := range []int64{
+		12, // 4 byte size + 5 bytes + 3 bytes of padding.
+		16,
+		16,
+		20,
+	} {
+		if _, err := r.ReadAsString(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.BytesConsumed(); expected != got {
+			t.Fatalf("argument %d: expected %d bytes consumed, got %d", i, expected, got)
+		}
+	}
+
+	// The total has to agree with the size of the encoded arguments.
+	data := bytes.Join([][]byte{Blob{1, 2, 3, 4, 5}.Bytes(), Int(7).Bytes(), String("bar").Bytes()}, nil)
+	if expected, got := int64(len(data)), r.BytesConsumed(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	_, n, err := ReadBlobFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := int64(12), n; expected != got {
+		t.Fatalf("expected ReadBlobFrom to consume %d bytes, got %d", expected, got)
+	}
+}
+func TestMessageArgsChan(t *testing.T) {
+	msg := Message{
+		Address: "/foo",
+		Arguments: Arguments{
+			Int(1),
+			Float(2.5),
+			Bool(true),
+			String("bar"),
+			Blob([]byte{1, 2}),
+			Nil{},
+			Impulse{},
+		},
+	}
+	expected := []ArgResult{
+		{Type: TypetagInt, Value: int32(1)},
+		{Type: TypetagFloat, Value: float32(2.5)},
+		{Type: TypetagTrue, Value: true},
+		{Type: TypetagString, Value: "bar"},
+		{Type: TypetagBlob, Value: []byte{1, 2}},
+		{Type: TypetagNil},
+		{Type: TypetagImpulse, Value: Impulse{}},
+	}
+	got := []ArgResult{}
+	for result := range msg.ArgsChan() {
+		got = append(got, result)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMessageArgs(t *testing.T) {
+	msg := Message{
+		Address:   "/foo",
+		Arguments: Arguments{Int(1), Float(2.5), Bool(false), String("bar"), Blob([]byte{1, 2})},
+	}
+	var (
+		it  = msg.Args()
+		got = []interface{}{}
+	)
+	for it.Next() {
+		switch it.Type() {
+		case TypetagInt:
+			got = append(got, it.Int32())
+		case TypetagFloat:
+			got = append(got, it.Float32())
+		case TypetagTrue, TypetagFalse:
+			got = append(got, it.Bool())
+		case TypetagString:
+			got = append(got, it.String())
+		case TypetagBlob:
+			b := it.Blob()
+			b[0] = 9 // Must not modify the message.
+			got = append(got, b)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{int32(1), float32(2.5), false, "bar", []byte{9, 2}}; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if expected, got := byte(1), []byte(msg.Arguments[4].(Blob))[0]; expected != got {
+		t.Fatalf("expected the message to be unchanged, got blob byte %d", got)
+	}
+	if it.Next() {
+		t.Fatal("expected Next to keep returning false")
+	}
+	// The wrong accessor stops the iteration.
+	it = msg.Args()
+	if !it.Next() {
+		t.Fatal("expected an argument")
+	}
+	if expected, got := "", it.String(); expected != got {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if it.Next() {
+		t.Fatal("expected Next to return false after an error")
+	}
+	if expected, got := "argument 0: invalid type tag", fmt.Sprint(it.Err()); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestArgumentReaderReadTime(t *testing.T) {
+	var (
+		msg = Message{Address: "/at"}
+		at  = time.Date(2026, time.October, 14, 12, 30, 15, 123456789, time.UTC)
+	)
+	msg.WriteTime(at)
+	msg.WriteTime(time.Time{})
+	msg.Arguments = append(msg.Arguments, Int(1))
+
+	parsed, err := ParseMessage(msg.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := parsed.Reader()
+
+	got, err := r.ReadTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at.Equal(got) {
+		t.Fatalf("expected %s, got %s", at, got)
+	}
+	immediately, err := r.ReadTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !immediately.IsZero() {
+		t.Fatalf("expected the zero time, got %s", immediately)
+	}
+	if _, err := r.ReadTime(); errors.Cause(err) != ErrInvalidTypeTag {
+		t.Fatalf("expected ErrInvalidTypeTag, got %v", err)
+	}
+	if expected, got := 1, r.Len(); expected != got {
+		t.Fatalf("expected %d arguments left, got %d", expected, got)
+	}
+}
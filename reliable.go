@@ -0,0 +1,403 @@
+package osc
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrTransferIncomplete = errors.New("blob transfer incomplete")
+	ErrTransferTooLarge   = errors.New("blob transfer too large")
+)
+
+// Defaults for ReliableBlobSender.
+const (
+	DefaultChunkSize = 8192
+	DefaultRetries   = 5
+	DefaultTimeout   = 250 * time.Millisecond
+)
+
+// Defaults for ReliableBlobReceiver.
+const (
+	DefaultMaxChunks   = 1024
+	DefaultTransferTTL = time.Minute
+)
+
+// AckAddress returns the address that acknowledgments for
+// chunks sent to address are sent to.
+func AckAddress(address string) string {
+	return address + "/ack"
+}
+
+// ReliableBlobSender sends a blob over an unreliable transport (e.g. UDP) as a sequence of chunks,
+// and retransmits the chunks that the receiver has not acknowledged.
+//
+// Each chunk is a message sent to Address with the arguments
+// transfer id (int), chunk index (int), number of chunks (int), and the chunk itself (blob).
+// The receiver acknowledges chunks with a message sent to AckAddress(Address)
+// whose arguments are the transfer id followed by the indices of all the chunks it has received.
+//
+// The sender has to be registered as the handler for AckAddress(Address)
+// with the dispatcher that serves the sender's connection.
+type ReliableBlobSender struct {
+	Address   string
+	ChunkSize int
+	Retries   int
+	Timeout   time.Duration
+
+	conn  Conn
+	raddr net.Addr
+
+	mu        sync.Mutex
+	nextID    int32
+	transfers map[int32]*outgoingTransfer
+}
+
+// outgoingTransfer tracks the chunks of a blob that have been acknowledged.
+type outgoingTransfer struct {
+	acked  []bool
+	count  int
+	signal chan struct{}
+}
+
+// NewReliableBlobSender creates a sender that sends chunks to address using conn.
+// If raddr is nil the chunks are sent with conn.Send, otherwise they are sent with conn.SendTo.
+func NewReliableBlobSender(conn Conn, raddr net.Addr, address string) *ReliableBlobSender {
+	return &ReliableBlobSender{
+		Address:   address,
+		ChunkSize: DefaultChunkSize,
+		Retries:   DefaultRetries,
+		Timeout:   DefaultTimeout,
+		conn:      conn,
+		raddr:     raddr,
+		transfers: map[int32]*outgoingTransfer{},
+	}
+}
+
+// Send sends a blob and blocks until every chunk has been acknowledged.
+// Chunks that have not been acknowledged after Timeout are sent again, at most Retries times.
+// ErrTransferIncomplete is returned if the receiver never acknowledges all of the chunks.
+func (s *ReliableBlobSender) Send(blob []byte) error {
+	var (
+		chunks = s.split(blob)
+		total  = int32(len(chunks))
+	)
+	id, transfer := s.start(len(chunks))
+	defer s.finish(id)
+
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		for i, chunk := range chunks {
+			if s.isAcked(transfer, i) {
+				continue
+			}
+			msg := Message{
+				Address: s.Address,
+				Arguments: Arguments{
+					Int(id),
+					Int(int32(i)),
+					Int(total),
+					Blob(chunk),
+				},
+			}
+			if err := s.send(msg); err != nil {
+				return errors.Wrapf(err, "send chunk %d", i)
+			}
+		}
+		if s.wait(transfer) {
+			return nil
+		}
+	}
+	s.mu.Lock()
+	count := transfer.count
+	s.mu.Unlock()
+
+	return errors.Wrapf(ErrTransferIncomplete, "%d of %d chunks acknowledged", count, total)
+}
+
+// Handle handles an acknowledgment from the receiver.
+func (s *ReliableBlobSender) Handle(msg Message) error {
+	r := msg.Reader()
+
+	id, err := r.ReadInt32()
+	if err != nil {
+		return errors.Wrap(err, "read transfer id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[id]
+	if !ok {
+		return nil // Late acknowledgment for a transfer that is already done.
+	}
+	for r.Len() > 0 {
+		idx, err := r.ReadInt32()
+		if err != nil {
+			return errors.Wrap(err, "read chunk index")
+		}
+		if idx < 0 || int(idx) >= len(transfer.acked) || transfer.acked[idx] {
+			continue
+		}
+		transfer.acked[idx] = true
+		transfer.count++
+	}
+	if transfer.count == len(transfer.acked) {
+		select {
+		case transfer.signal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// finish forgets about a transfer.
+func (s *ReliableBlobSender) finish(id int32) {
+	s.mu.Lock()
+	delete(s.transfers, id)
+	s.mu.Unlock()
+}
+
+// isAcked returns true if the receiver has acknowledged the chunk at index i.
+func (s *ReliableBlobSender) isAcked(transfer *outgoingTransfer, i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return transfer.acked[i]
+}
+
+// send sends a chunk message.
+func (s *ReliableBlobSender) send(msg Message) error {
+	if s.raddr == nil {
+		return s.conn.Send(msg)
+	}
+	return s.conn.SendTo(s.raddr, msg)
+}
+
+// split splits a blob into chunks.
+// An empty blob is sent as a single empty chunk.
+func (s *ReliableBlobSender) split(blob []byte) [][]byte {
+	size := s.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	chunks := [][]byte{}
+	for len(blob) > size {
+		chunks = append(chunks, blob[:size])
+		blob = blob[size:]
+	}
+	return append(chunks, blob)
+}
+
+// start registers a new transfer.
+func (s *ReliableBlobSender) start(numChunks int) (int32, *outgoingTransfer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+
+	transfer := &outgoingTransfer{
+		acked:  make([]bool, numChunks),
+		signal: make(chan struct{}, 1),
+	}
+	s.transfers[id] = transfer
+	return id, transfer
+}
+
+// wait waits for all the chunks of a transfer to be acknowledged.
+// It returns false if that does not happen before the timeout.
+func (s *ReliableBlobSender) wait(transfer *outgoingTransfer) bool {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	select {
+	case <-transfer.signal:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// ReliableBlobReceiver reassembles blobs sent by a ReliableBlobSender
+// and acknowledges every chunk it receives.
+// It has to be registered as the handler for the address the sender sends chunks to.
+//
+// Chunks come from the network, so the receiver limits what they can make it hold on to:
+// transfers of more than MaxChunks chunks are rejected with an error whose cause is ErrTransferTooLarge,
+// and transfers that have not received a chunk for TransferTTL are forgotten.
+// Finished transfers are remembered without their chunks for TransferTTL,
+// so that chunks the sender sends again because an ack was lost are still acknowledged.
+type ReliableBlobReceiver struct {
+	MaxChunks   int
+	TransferTTL time.Duration
+
+	conn    Conn
+	address string
+	fn      func(blob []byte, sender net.Addr) error
+	now     func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	transfers map[transferKey]*incomingTransfer
+}
+
+// transferKey identifies a transfer.
+// Transfer ids are only unique per sender.
+type transferKey struct {
+	sender string
+	id     int32
+}
+
+// incomingTransfer holds the chunks of a blob that have been received.
+// The chunks of a transfer that is done have been handed over and are nil.
+type incomingTransfer struct {
+	chunks [][]byte
+	count  int
+	done   bool
+	last   time.Time // When the last chunk arrived.
+	total  int
+}
+
+// NewReliableBlobReceiver creates a receiver that acknowledges chunks sent to address using conn.
+// fn is called with each blob once all of its chunks have arrived.
+func NewReliableBlobReceiver(conn Conn, address string, fn func(blob []byte, sender net.Addr) error) *ReliableBlobReceiver {
+	return &ReliableBlobReceiver{
+		MaxChunks:   DefaultMaxChunks,
+		TransferTTL: DefaultTransferTTL,
+		conn:        conn,
+		address:     address,
+		fn:          fn,
+		now:         time.Now,
+		transfers:   map[transferKey]*incomingTransfer{},
+	}
+}
+
+// Handle handles a chunk.
+func (r *ReliableBlobReceiver) Handle(msg Message) error {
+	id, idx, total, chunk, err := readChunk(msg)
+	if err != nil {
+		return err
+	}
+	if max := r.maxChunks(); int(total) > max {
+		return errors.Wrapf(ErrTransferTooLarge, "chunk %d of transfer %d: %d chunks, the maximum is %d", idx, id, total, max)
+	}
+	key := transferKey{id: id}
+	if msg.Sender != nil {
+		key.sender = msg.Sender.String()
+	}
+	now := r.now()
+
+	r.mu.Lock()
+	r.sweep(now)
+
+	transfer, ok := r.transfers[key]
+	if !ok {
+		transfer = &incomingTransfer{chunks: make([][]byte, total), total: int(total)}
+		r.transfers[key] = transfer
+	}
+	if int(total) != transfer.total {
+		r.mu.Unlock()
+		return errors.Errorf("chunk %d of transfer %d: expected %d chunks, got %d", idx, id, transfer.total, total)
+	}
+	transfer.last = now
+
+	if !transfer.done && transfer.chunks[idx] == nil {
+		transfer.chunks[idx] = append([]byte{}, chunk...)
+		transfer.count++
+	}
+	var (
+		ack  = transfer.ack(id, r.address)
+		blob []byte
+	)
+	if !transfer.done && transfer.count == transfer.total {
+		blob = transfer.finish()
+	}
+	r.mu.Unlock()
+
+	if err := r.conn.SendTo(msg.Sender, ack); err != nil {
+		return errors.Wrap(err, "send ack")
+	}
+	if blob == nil {
+		return nil
+	}
+	return r.fn(blob, msg.Sender)
+}
+
+// maxChunks returns the maximum number of chunks of a transfer.
+func (r *ReliableBlobReceiver) maxChunks() int {
+	if r.MaxChunks <= 0 {
+		return DefaultMaxChunks
+	}
+	return r.MaxChunks
+}
+
+// sweep forgets the transfers that have not received a chunk for TransferTTL.
+// It only looks at the transfers once per TransferTTL.
+// The caller must hold r.mu.
+func (r *ReliableBlobReceiver) sweep(now time.Time) {
+	ttl := r.TransferTTL
+	if ttl <= 0 {
+		ttl = DefaultTransferTTL
+	}
+	if now.Sub(r.lastSweep) < ttl {
+		return
+	}
+	r.lastSweep = now
+
+	for key, transfer := range r.transfers {
+		if now.Sub(transfer.last) >= ttl {
+			delete(r.transfers, key)
+		}
+	}
+}
+
+// finish marks the transfer as done and returns the blob.
+// The chunks are not kept, only the fact that the transfer is done.
+func (transfer *incomingTransfer) finish() []byte {
+	blob := []byte{}
+	for _, chunk := range transfer.chunks {
+		blob = append(blob, chunk...)
+	}
+	transfer.chunks = nil
+	transfer.done = true
+	return blob
+}
+
+// ack returns a message that acknowledges all the chunks in the transfer that have been received.
+func (transfer *incomingTransfer) ack(id int32, address string) Message {
+	msg := Message{
+		Address:   AckAddress(address),
+		Arguments: Arguments{Int(id)},
+	}
+	for i := 0; i < transfer.total; i++ {
+		if transfer.done || transfer.chunks[i] != nil {
+			msg.Arguments = append(msg.Arguments, Int(int32(i)))
+		}
+	}
+	return msg
+}
+
+// readChunk reads the arguments of a chunk message.
+func readChunk(msg Message) (id, idx, total int32, chunk []byte, err error) {
+	r := msg.Reader()
+
+	if id, err = r.ReadInt32(); err != nil {
+		return 0, 0, 0, nil, errors.Wrap(err, "read transfer id")
+	}
+	if idx, err = r.ReadInt32(); err != nil {
+		return 0, 0, 0, nil, errors.Wrap(err, "read chunk index")
+	}
+	if total, err = r.ReadInt32(); err != nil {
+		return 0, 0, 0, nil, errors.Wrap(err, "read number of chunks")
+	}
+	if chunk, err = r.ReadBlob(); err != nil {
+		return 0, 0, 0, nil, errors.Wrap(err, "read chunk")
+	}
+	if total <= 0 || idx < 0 || idx >= total {
+		return 0, 0, 0, nil, errors.Errorf("chunk %d of transfer %d: invalid chunk index for %d chunks", idx, id, total)
+	}
+	return id, idx, total, chunk, nil
+}
@@ -0,0 +1,213 @@
+package osc
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// dropConn drops the first message sent for each of the chunk indices in drop.
+type dropConn struct {
+	Conn
+
+	mu      sync.Mutex
+	drop    map[int32]bool
+	dropped []int32
+}
+
+func (conn *dropConn) SendTo(addr net.Addr, p Packet) error {
+	if msg, ok := p.(Message); ok && len(msg.Arguments) > 1 {
+		idx, err := msg.Arguments[1].ReadInt32()
+		if err != nil {
+			return err
+		}
+		conn.mu.Lock()
+		drop := conn.drop[idx]
+		if drop {
+			delete(conn.drop, idx)
+			conn.dropped = append(conn.dropped, idx)
+		}
+		conn.mu.Unlock()
+
+		if drop {
+			return nil
+		}
+	}
+	return conn.Conn.SendTo(addr, p)
+}
+
+func TestReliableBlobTransfer(t *testing.T) {
+	const addr = "/sample/upload"
+
+	var (
+		blobChan = make(chan []byte, 1)
+		errChan  = make(chan error, 2)
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverConn, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = receiverConn.Close() }() // Best effort.
+
+	senderConn, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = senderConn.Close() }() // Best effort.
+
+	receiver := NewReliableBlobReceiver(receiverConn, addr, func(blob []byte, sender net.Addr) error {
+		blobChan <- blob
+		return nil
+	})
+	conn := &dropConn{Conn: senderConn, drop: map[int32]bool{1: true}}
+	sender := NewReliableBlobSender(conn, receiverConn.LocalAddr(), addr)
+	sender.ChunkSize = 16
+	sender.Timeout = 50 * time.Millisecond
+
+	go func() {
+		errChan <- receiverConn.Serve(1, PatternMatching{addr: receiver})
+	}()
+	go func() {
+		errChan <- senderConn.Serve(1, PatternMatching{AckAddress(addr): sender})
+	}()
+
+	blob := bytes.Repeat([]byte("0123456789"), 10)
+
+	if err := sender.Send(blob); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-blobChan:
+		if !bytes.Equal(blob, got) {
+			t.Fatalf("expected %q, got %q", blob, got)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for blob")
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if expected, got := 1, len(conn.dropped); expected != got {
+		t.Fatalf("expected %d dropped chunks, got %d", expected, got)
+	}
+}
+
+func TestReliableBlobTransferIncomplete(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	senderConn, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = senderConn.Close() }() // Best effort.
+
+	// Nobody is listening, so nothing will ever be acknowledged.
+	sender := NewReliableBlobSender(senderConn, senderConn.LocalAddr(), "/sample/upload")
+	sender.Retries = 2
+	sender.Timeout = 10 * time.Millisecond
+
+	if err := sender.Send([]byte("foo")); errors.Cause(err) != ErrTransferIncomplete {
+		t.Fatalf("expected ErrTransferIncomplete, got %+v", err)
+	}
+}
+
+func TestReliableBlobReceiverBadChunk(t *testing.T) {
+	r := NewReliableBlobReceiver(nil, "/sample/upload", nil)
+
+	for i, msg := range []Message{
+		{Address: "/sample/upload"},
+		{Address: "/sample/upload", Arguments: Arguments{Int(0), Int(2), Int(2), Blob{}}},
+		{Address: "/sample/upload", Arguments: Arguments{Int(0), Int(0), Int(0), Blob{}}},
+		{Address: "/sample/upload", Arguments: Arguments{Int(0), Int(0), Int(1), String("foo")}},
+	} {
+		if err := r.Handle(msg); err == nil {
+			t.Fatalf("(message %d) expected error, got nil", i)
+		}
+	}
+}
+
+// ackConn records the packets sent to it.
+type ackConn struct {
+	Conn
+
+	sent []Packet
+}
+
+func (conn *ackConn) SendTo(addr net.Addr, p Packet) error {
+	conn.sent = append(conn.sent, p)
+	return nil
+}
+
+func TestReliableBlobReceiverTooLarge(t *testing.T) {
+	r := NewReliableBlobReceiver(&ackConn{}, "/sample/upload", nil)
+
+	msg := Message{Address: "/sample/upload", Arguments: Arguments{Int(0), Int(0), Int(1<<31 - 1), Blob{}}}
+	if err := r.Handle(msg); errors.Cause(err) != ErrTransferTooLarge {
+		t.Fatalf("expected ErrTransferTooLarge, got %v", err)
+	}
+	if expected, got := 0, len(r.transfers); expected != got {
+		t.Fatalf("expected %d transfers, got %d", expected, got)
+	}
+}
+
+func TestReliableBlobReceiverExpire(t *testing.T) {
+	var (
+		blobs = [][]byte{}
+		conn  = &ackConn{}
+		now   = time.Unix(0, 0)
+	)
+	r := NewReliableBlobReceiver(conn, "/sample/upload", func(blob []byte, sender net.Addr) error {
+		blobs = append(blobs, blob)
+		return nil
+	})
+	r.now = func() time.Time { return now }
+
+	chunk := func(id, idx, total int32, data string) Message {
+		return Message{Address: "/sample/upload", Arguments: Arguments{Int(id), Int(idx), Int(total), Blob(data)}}
+	}
+	for _, msg := range []Message{
+		chunk(0, 0, 2, "foo"),
+		chunk(0, 1, 2, "bar"), // Transfer 0 is done.
+		chunk(1, 0, 2, "baz"), // Transfer 1 never will be.
+	} {
+		if err := r.Handle(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected, got := [][]byte{[]byte("foobar")}, blobs; len(got) != 1 || !bytes.Equal(expected[0], got[0]) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if r.transfers[transferKey{id: 0}].chunks != nil {
+		t.Fatal("expected the chunks of the finished transfer to be dropped")
+	}
+	// A duplicate chunk of the finished transfer is acknowledged again, but not delivered again.
+	if err := r.Handle(chunk(0, 1, 2, "bar")); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (Message{Address: AckAddress("/sample/upload"), Arguments: Arguments{Int(0), Int(0), Int(1)}}), conn.sent[len(conn.sent)-1]; !expected.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	if expected, got := 1, len(blobs); expected != got {
+		t.Fatalf("expected %d blobs, got %d", expected, got)
+	}
+	// Once the TTL has passed both transfers are forgotten.
+	now = now.Add(DefaultTransferTTL)
+
+	if err := r.Handle(chunk(2, 0, 2, "qux")); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(r.transfers); expected != got {
+		t.Fatalf("expected %d transfers, got %d", expected, got)
+	}
+}
@@ -0,0 +1,86 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults for RetryOptions.
+const (
+	DefaultInitialBackoff = 50 * time.Millisecond
+	DefaultMaxBackoff     = 5 * time.Second
+)
+
+// RetryOptions configures how DialUDPRetry and ListenUDPRetry retry.
+// The zero value retries with the default backoff until the context is done.
+type RetryOptions struct {
+	// InitialBackoff is how long to wait after the first failed attempt.
+	// The wait doubles after every failed attempt, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxAttempts limits the number of attempts, if it is positive.
+	MaxAttempts int
+}
+
+// DialUDPRetry is like DialUDPContext, but it retries with exponential backoff
+// until the dial succeeds, ctx is done, or opts.MaxAttempts attempts have failed.
+// If ctx is done first the cause of the returned error is the context's error.
+func DialUDPRetry(ctx context.Context, network string, laddr, raddr *net.UDPAddr, opts RetryOptions) (*UDPConn, error) {
+	var conn *UDPConn
+
+	err := retry(ctx, opts, func() (err error) {
+		conn, err = DialUDPContext(ctx, network, laddr, raddr)
+		return err
+	})
+	return conn, err
+}
+
+// ListenUDPRetry is like ListenUDPContext, but it retries with exponential backoff
+// until it can listen, ctx is done, or opts.MaxAttempts attempts have failed.
+// This is useful when laddr is still in use, e.g. by a process that is exiting.
+// If ctx is done first the cause of the returned error is the context's error.
+func ListenUDPRetry(ctx context.Context, network string, laddr *net.UDPAddr, opts RetryOptions) (*UDPConn, error) {
+	var conn *UDPConn
+
+	err := retry(ctx, opts, func() (err error) {
+		conn, err = ListenUDPContext(ctx, network, laddr)
+		return err
+	})
+	return conn, err
+}
+
+// retry calls attempt until it succeeds, backing off after every failure.
+func retry(ctx context.Context, opts RetryOptions, attempt func() error) error {
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	backoff := opts.InitialBackoff
+
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if opts.MaxAttempts > 0 && i >= opts.MaxAttempts {
+			return errors.Wrapf(err, "giving up after %d attempts", i)
+		}
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrapf(ctx.Err(), "attempt %d failed with %q", i, err)
+		}
+		if backoff *= 2; backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRetry(t *testing.T) {
+	var (
+		attempts = 0
+		opts     = RetryOptions{InitialBackoff: time.Millisecond}
+	)
+	if err := retry(context.Background(), opts, func() error {
+		if attempts++; attempts <= 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 4, attempts; expected != got {
+		t.Fatalf("expected %d attempts, got %d", expected, got)
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	fail := func() error { return errors.New("oops") }
+
+	err := retry(context.Background(), RetryOptions{InitialBackoff: time.Millisecond, MaxAttempts: 3}, fail)
+	if expected, got := "giving up after 3 attempts: oops", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = retry(ctx, RetryOptions{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, fail)
+	if expected, got := context.DeadlineExceeded, errors.Cause(err); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestListenUDPRetry(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := busy.LocalAddr().(*net.UDPAddr)
+
+	// The port is released after the first few attempts have failed.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = busy.Close() // Best effort.
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := ListenUDPRetry(ctx, "udp", addr, RetryOptions{InitialBackoff: 5 * time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	if expected, got := addr.String(), conn.LocalAddr().String(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDialUDPRetry(t *testing.T) {
+	raddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:9999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := DialUDPRetry(context.Background(), "udp", nil, raddr, RetryOptions{MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close() // Best effort.
+
+	if _, err := DialUDPRetry(context.Background(), "bogus", nil, raddr, RetryOptions{InitialBackoff: time.Millisecond, MaxAttempts: 2}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
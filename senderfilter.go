@@ -0,0 +1,64 @@
+package osc
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrSenderRejected = errors.New("sender rejected")
+)
+
+// AllowCIDRs returns a sender filter, for SetSenderFilter, that accepts senders
+// whose IP is in one of the provided networks, e.g. "127.0.0.1/32" or "10.0.0.0/8".
+// Senders that do not have an IP are rejected.
+func AllowCIDRs(cidrs ...string) (func(net.Addr) bool, error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return func(sender net.Addr) bool {
+		return containsSender(nets, sender)
+	}, nil
+}
+
+// DenyCIDRs returns a sender filter, for SetSenderFilter, that rejects senders
+// whose IP is in one of the provided networks and accepts everyone else.
+func DenyCIDRs(cidrs ...string) (func(net.Addr) bool, error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return func(sender net.Addr) bool {
+		return !containsSender(nets, sender)
+	}, nil
+}
+
+// parseCIDRs parses networks in CIDR notation.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse network %d", i)
+		}
+		nets[i] = ipnet
+	}
+	return nets, nil
+}
+
+// containsSender returns true if the IP of sender is in one of the networks.
+func containsSender(nets []*net.IPNet, sender net.Addr) bool {
+	ip := (Message{Sender: sender}).SenderIP()
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
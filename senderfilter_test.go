@@ -0,0 +1,40 @@
+package osc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSenderFilters(t *testing.T) {
+	allow, err := AllowCIDRs("127.0.0.1/32", "10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deny, err := DenyCIDRs("127.0.0.1/32", "10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, testcase := range []struct {
+		Sender  net.Addr
+		Allowed bool
+	}{
+		{Sender: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, Allowed: true},
+		{Sender: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 1}, Allowed: false},
+		{Sender: &net.UDPAddr{IP: net.IPv4(10, 1, 2, 3), Port: 1}, Allowed: true},
+		{Sender: &net.TCPAddr{IP: net.IPv4(10, 1, 2, 3), Port: 1}, Allowed: true},
+		{Sender: &net.UDPAddr{IP: net.IPv6loopback, Port: 1}, Allowed: false},
+	} {
+		if expected, got := testcase.Allowed, allow(testcase.Sender); expected != got {
+			t.Fatalf("(%s) expected allow to return %t, got %t", testcase.Sender, expected, got)
+		}
+		if expected, got := !testcase.Allowed, deny(testcase.Sender); expected != got {
+			t.Fatalf("(%s) expected deny to return %t, got %t", testcase.Sender, expected, got)
+		}
+	}
+	if allow(nil) {
+		t.Fatal("expected allow to reject a nil sender")
+	}
+	if _, err := AllowCIDRs("127.0.0.1"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
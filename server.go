@@ -0,0 +1,233 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrServerListening    = errors.New("server is already listening")
+	ErrServerNotListening = errors.New("server is not listening")
+)
+
+// Server is a high-level OSC server that listens on a UDP address
+// and dispatches messages to the handlers that are added to it.
+type Server struct {
+	Addr string
+
+	// Listening receives the result of every attempt to start listening,
+	// which is nil once the server is ready to receive packets.
+	// It is buffered, and the server never blocks sending on it.
+	Listening chan error
+
+	mu            sync.Mutex
+	addressSchema *regexp.Regexp
+	conn          *UDPConn
+	deadLetter    MessageHandler
+	dispatcher    PatternMatching
+	handleErr     error
+	logger        LogFunc
+}
+
+// NewServer creates a server that will listen on addr, which is a "host:port" string.
+func NewServer(addr string) *Server {
+	return &Server{
+		Addr:       addr,
+		Listening:  make(chan error, 1),
+		dispatcher: PatternMatching{},
+	}
+}
+
+// AddMsgHandler adds a handler for messages that match addr.
+// An error is returned if addr is not a valid OSC address, if it does not
+// match the server's address schema, or if the server is already listening.
+func (s *Server) AddMsgHandler(addr string, method Method) error {
+	return s.add(addr, method)
+}
+
+// Handle adds a handler for messages that match addr and returns the server,
+// so that calls can be chained:
+//
+//	err := NewServer(addr).Handle("/a", h1).Handle("/b", h2).Serve(numWorkers)
+//
+// Addresses are validated the same way AddMsgHandler validates them,
+// and the first error is returned by Serve.
+func (s *Server) Handle(addr string, handler MessageHandler) *Server {
+	if err := s.add(addr, handler); err != nil {
+		s.mu.Lock()
+		if s.handleErr == nil {
+			s.handleErr = errors.Wrapf(err, "handle %s", addr)
+		}
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// add adds a handler to the server's dispatcher.
+func (s *Server) add(addr string, handler MessageHandler) error {
+	if err := ValidateAddress(addr); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := checkAddressSchema(addr, s.addressSchema); err != nil {
+		return err
+	}
+	if s.conn != nil {
+		return ErrServerListening
+	}
+	s.dispatcher[addr] = handler
+	return nil
+}
+
+// Close stops the server.
+// ErrPrematureClose is returned if the server is not listening.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return ErrPrematureClose
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// ListenAndDispatch listens on the server's address and dispatches
+// the messages it receives until the server is closed.
+// Messages are handled one at a time, in the order they are received.
+func (s *Server) ListenAndDispatch() error {
+	return s.Serve(1)
+}
+
+// Serve listens on the server's address and dispatches the messages
+// it receives with numWorkers workers until the server is closed.
+// If there was an error adding any of the handlers passed to Handle it is returned
+// without listening.
+func (s *Server) Serve(numWorkers int) error {
+	s.mu.Lock()
+	err := s.handleErr
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	var (
+		conn       = s.conn
+		dispatcher = s.dispatcher
+	)
+	if s.logger != nil {
+		dispatcher = dispatcher.Log(s.logger)
+	}
+	if s.deadLetter != nil {
+		dispatcher = dispatcher.Use(DeadLetter(s.deadLetter))
+	}
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil // Closed before serving started.
+	}
+	return conn.Serve(numWorkers, dispatcher)
+}
+
+// Listen starts listening on the server's address, without dispatching anything.
+// Use it together with ReceivePacket to handle packets yourself instead of calling Serve.
+// The result is also sent on the Listening chan.
+func (s *Server) Listen() error {
+	err := s.listen()
+	select {
+	case s.Listening <- err:
+	default:
+	}
+	return err
+}
+
+// listen starts listening on the server's address.
+func (s *Server) listen() error {
+	laddr, err := net.ResolveUDPAddr("udp", s.Addr)
+	if err != nil {
+		return errors.Wrap(err, "resolve address")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return ErrServerListening
+	}
+	conn, err := ListenUDPContext(context.Background(), "udp", laddr)
+	if err != nil {
+		return errors.Wrap(err, "listen")
+	}
+	s.conn = conn
+	return nil
+}
+
+// ReceivePacket blocks until the server receives a packet and returns it.
+// ErrServerNotListening is returned if Listen has not been called.
+// It must not be used on a server that is being served.
+func (s *Server) ReceivePacket() (Packet, error) {
+	return s.ReceivePacketContext(context.Background())
+}
+
+// ReceivePacketContext is like ReceivePacket, but it gives up when ctx is done
+// and returns the context's error.
+func (s *Server) ReceivePacketContext(ctx context.Context) (Packet, error) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil, ErrServerNotListening
+	}
+	p, _, err := conn.ReadPacketContext(ctx)
+	return p, err
+}
+
+// LocalAddr returns the address the server is listening on,
+// or nil if the server is not listening.
+func (s *Server) LocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
+
+// SetDeadLetter makes Serve hand the messages that handlers return errors for
+// to h instead of stopping, see DeadLetter.
+// It must be called before Serve. Passing nil restores the default behavior.
+func (s *Server) SetDeadLetter(h MessageHandler) {
+	s.mu.Lock()
+	s.deadLetter = h
+	s.mu.Unlock()
+}
+
+// SetLogger makes Serve report every message that a handler handles to fn, see PatternMatching.Log.
+// fn sees the errors of the handlers before the dead letter handler, if there is one, does.
+// It must be called before Serve. Passing nil disables logging.
+func (s *Server) SetLogger(fn LogFunc) {
+	s.mu.Lock()
+	s.logger = fn
+	s.mu.Unlock()
+}
+
+// SetAddressSchema makes AddMsgHandler reject addresses that do not match the provided regular expression.
+// Passing nil disables the check.
+func (s *Server) SetAddressSchema(re *regexp.Regexp) {
+	s.mu.Lock()
+	s.addressSchema = re
+	s.mu.Unlock()
+}
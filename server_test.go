@@ -0,0 +1,347 @@
+package osc
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// waitListening waits for a server to start listening and returns its address.
+func waitListening(t *testing.T, s *Server) net.Addr {
+	deadline := time.Now().Add(time.Second)
+	for {
+		if addr := s.LocalAddr(); addr != nil {
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for server to listen")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer(t *testing.T) {
+	var (
+		msgChan = make(chan Message, 1)
+		errChan = make(chan error, 1)
+		server  = NewServer("127.0.0.1:0")
+	)
+	if err := server.AddMsgHandler("/transport/play", func(msg Message) error {
+		msgChan <- msg
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		errChan <- server.ListenAndDispatch()
+	}()
+	client, err := NewClient(waitListening(t, server).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	// Handlers can not be added while the server is listening.
+	if err := server.AddMsgHandler("/transport/stop", func(msg Message) error {
+		return nil
+	}); err != ErrServerListening {
+		t.Fatalf("expected ErrServerListening, got %+v", err)
+	}
+	if err := client.Send(Message{Address: "/transport/play"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgChan:
+		if expected, got := "/transport/play", msg.Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerAddMsgHandlerInvalidAddress(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+
+	if err := server.AddMsgHandler("/foo/[", func(msg Message) error {
+		return nil
+	}); err != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %+v", err)
+	}
+}
+
+func TestServerAddressSchema(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+	server.SetAddressSchema(regexp.MustCompile(`^/[a-z]+(/[a-z0-9]+)*$`))
+
+	if err := server.AddMsgHandler("/mixer/ch1/gain", func(msg Message) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddMsgHandler("/mixer/Ch1/gain", func(msg Message) error {
+		return nil
+	}); errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %+v", err)
+	}
+}
+
+func TestServerClosePremature(t *testing.T) {
+	if err := NewServer("127.0.0.1:0").Close(); err != ErrPrematureClose {
+		t.Fatalf("expected ErrPrematureClose, got %+v", err)
+	}
+}
+
+func TestServerHandle(t *testing.T) {
+	var (
+		msgChan = make(chan Message, 2)
+		errChan = make(chan error, 1)
+		handler = Method(func(msg Message) error {
+			msgChan <- msg
+			return nil
+		})
+		server = NewServer("127.0.0.1:0")
+	)
+	go func() {
+		errChan <- server.Handle("/a", handler).Handle("/b", handler).Serve(2)
+	}()
+	client, err := NewClient(waitListening(t, server).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	for _, addr := range []string{"/a", "/b"} {
+		if err := client.Send(Message{Address: addr}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-msgChan:
+			if expected, got := addr, msg.Address; expected != got {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerHandleInvalidAddress(t *testing.T) {
+	handler := Method(func(msg Message) error {
+		return nil
+	})
+	err := NewServer("127.0.0.1:0").Handle("/a", handler).Handle("/b*", handler).Handle("/c", handler).Serve(1)
+	if errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %+v", err)
+	}
+	if expected, got := "handle /b*: invalid OSC address", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestServerMessageReceiving(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+
+	if _, err := server.ReceivePacket(); err != ErrServerNotListening {
+		t.Fatalf("expected ErrServerNotListening, got %+v", err)
+	}
+	go func() {
+		_ = server.Listen() // The error is sent on the Listening chan.
+	}()
+	select {
+	case err := <-server.Listening:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for server to listen")
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	if err := server.Listen(); err != ErrServerListening {
+		t.Fatalf("expected ErrServerListening, got %+v", err)
+	}
+	<-server.Listening
+
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	msg := Message{Address: "/osc/address", Arguments: Arguments{Int(111), Bool(true), String("foo")}}
+	if err := client.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	p, err := server.ReceivePacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := p.(Message)
+	if !ok {
+		t.Fatalf("expected a message, got %T", p)
+	}
+	if expected, got := 3, len(got.Arguments); expected != got {
+		t.Fatalf("expected %d arguments, got %d", expected, got)
+	}
+	if !msg.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", msg, got)
+	}
+}
+
+func TestServerReceivePacketContext(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := server.ReceivePacketContext(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %+v", err)
+	}
+	// The server can still receive packets afterwards.
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	msg := Message{Address: "/foo"}
+	if err := client.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	p, err := server.ReceivePacketContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(p) {
+		t.Fatalf("expected %+v, got %+v", msg, p)
+	}
+}
+
+func TestServerDeadLetter(t *testing.T) {
+	var (
+		deadChan = make(chan Message, 1)
+		errChan  = make(chan error, 1)
+		server   = NewServer("127.0.0.1:0")
+	)
+	server.SetDeadLetter(Method(func(msg Message) error {
+		deadChan <- msg
+		return nil
+	}))
+	if err := server.AddMsgHandler("/fail", func(msg Message) error {
+		return errors.New("oops")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		errChan <- server.ListenAndDispatch()
+	}()
+	client, err := NewClient(waitListening(t, server).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	msg := Message{Address: "/fail", Arguments: Arguments{Int(1), String("foo")}}
+	for i := 0; i < 2; i++ { // The server keeps going after the first failure.
+		if err := client.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-deadChan:
+			if !msg.Equal(got) {
+				t.Fatalf("expected %+v, got %+v", msg, got)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for dead letter")
+		}
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerLogger(t *testing.T) {
+	type entry struct {
+		address string
+		err     error
+	}
+	var (
+		entries = make(chan entry, 1)
+		errChan = make(chan error, 1)
+		server  = NewServer("127.0.0.1:0")
+	)
+	server.SetLogger(func(address string, dur time.Duration, err error) {
+		entries <- entry{address: address, err: err}
+	})
+	server.SetDeadLetter(Method(func(msg Message) error { return nil }))
+
+	if err := server.AddMsgHandler("/fail", func(msg Message) error {
+		return errors.New("oops")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		errChan <- server.ListenAndDispatch()
+	}()
+	client, err := NewClient(waitListening(t, server).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(Message{Address: "/fail"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-entries:
+		if expected := "/fail"; expected != got.address {
+			t.Fatalf("expected %s, got %s", expected, got.address)
+		}
+		if got.err == nil || got.err.Error() != "oops" {
+			t.Fatalf("expected oops, got %+v", got.err)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for log entry")
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package osc
+
+import (
+	"bufio"
+	"io"
+)
+
+// SLIP special characters.
+// See https://tools.ietf.org/html/rfc1055
+const (
+	SlipEnd    byte = 0xC0
+	SlipEsc    byte = 0xDB
+	SlipEscEnd byte = 0xDC
+	SlipEscEsc byte = 0xDD
+)
+
+// SlipEncode encodes a packet with SLIP framing (RFC 1055).
+// The packet is both preceded and followed by an END byte,
+// which is the double-ended flavor of SLIP recommended by OSC 1.1.
+func SlipEncode(p []byte) []byte {
+	enc := make([]byte, 0, len(p)+2)
+	enc = append(enc, SlipEnd)
+	for _, b := range p {
+		switch b {
+		case SlipEnd:
+			enc = append(enc, SlipEsc, SlipEscEnd)
+		case SlipEsc:
+			enc = append(enc, SlipEsc, SlipEscEsc)
+		default:
+			enc = append(enc, b)
+		}
+	}
+	return append(enc, SlipEnd)
+}
+
+// SlipReader reads SLIP-framed packets from an io.Reader.
+// It does not care what kind of transport the reader is,
+// so it can be used with TCP connections as well as serial ports.
+type SlipReader struct {
+	r *bufio.Reader
+}
+
+// NewSlipReader creates a new SlipReader.
+func NewSlipReader(r io.Reader) *SlipReader {
+	return &SlipReader{r: bufio.NewReader(r)}
+}
+
+// ReadPacket reads and decodes the next packet.
+// Empty packets, e.g. between two END bytes, are skipped.
+// If the reader runs out of data in the middle of a packet
+// io.ErrUnexpectedEOF is returned.
+func (sr *SlipReader) ReadPacket() ([]byte, error) {
+	var (
+		p       = []byte{}
+		escaped bool
+	)
+	for {
+		b, err := sr.r.ReadByte()
+		if err == io.EOF && len(p) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if escaped {
+			escaped = false
+			switch b {
+			case SlipEscEnd:
+				b = SlipEnd
+			case SlipEscEsc:
+				b = SlipEsc
+			}
+			// RFC 1055 says that any other byte following ESC
+			// is a protocol violation and should be left alone.
+			p = append(p, b)
+			continue
+		}
+		switch b {
+		case SlipEnd:
+			if len(p) > 0 {
+				return p, nil
+			}
+		case SlipEsc:
+			escaped = true
+		default:
+			p = append(p, b)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package osc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSlipEncode(t *testing.T) {
+	for i, testcase := range []struct {
+		Input    []byte
+		Expected []byte
+	}{
+		{
+			Input:    []byte{},
+			Expected: []byte{SlipEnd, SlipEnd},
+		},
+		{
+			Input:    []byte{'/', 'f', 'o', 'o'},
+			Expected: []byte{SlipEnd, '/', 'f', 'o', 'o', SlipEnd},
+		},
+		{
+			Input:    []byte{1, SlipEnd, 2, SlipEsc, 3},
+			Expected: []byte{SlipEnd, 1, SlipEsc, SlipEscEnd, 2, SlipEsc, SlipEscEsc, 3, SlipEnd},
+		},
+	} {
+		if expected, got := testcase.Expected, SlipEncode(testcase.Input); !bytes.Equal(expected, got) {
+			t.Fatalf("(testcase %d) expected %x, got %x", i, expected, got)
+		}
+	}
+}
+
+func TestSlipReader(t *testing.T) {
+	packets := [][]byte{
+		Message{Address: "/foo", Arguments: Arguments{Blob([]byte{SlipEnd, SlipEsc})}}.Bytes(),
+		{SlipEsc, SlipEsc, SlipEnd},
+		Message{Address: "/bar", Arguments: Arguments{Int(0xC0DB)}}.Bytes(),
+	}
+	stream := &bytes.Buffer{}
+	for _, p := range packets {
+		stream.Write(SlipEncode(p))
+	}
+	r := NewSlipReader(stream)
+
+	for i, expected := range packets {
+		got, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+		if !bytes.Equal(expected, got) {
+			t.Fatalf("(packet %d) expected %x, got %x", i, expected, got)
+		}
+	}
+	if _, err := r.ReadPacket(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %+v", err)
+	}
+}
+
+func TestSlipReaderSingleEnded(t *testing.T) {
+	r := NewSlipReader(bytes.NewReader([]byte{'a', SlipEnd, 'b', SlipEsc, 'x', SlipEnd}))
+
+	for i, expected := range [][]byte{{'a'}, {'b', 'x'}} {
+		got, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("(packet %d) %s", i, err)
+		}
+		if !bytes.Equal(expected, got) {
+			t.Fatalf("(packet %d) expected %x, got %x", i, expected, got)
+		}
+	}
+}
+
+func TestSlipReaderUnexpectedEOF(t *testing.T) {
+	r := NewSlipReader(bytes.NewReader([]byte{SlipEnd, 'a', 'b'}))
+
+	if _, err := r.ReadPacket(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %+v", err)
+	}
+}
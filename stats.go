@@ -0,0 +1,56 @@
+package osc
+
+import (
+	"sync/atomic"
+)
+
+// Stats are counters that describe the traffic a conn has served.
+type Stats struct {
+	// PacketsReceived is the number of packets that have been read,
+	// including the ones that were dropped by a sender filter or a rate limit.
+	PacketsReceived uint64
+
+	// ParseErrors is the number of packets that could not be parsed.
+	ParseErrors uint64
+
+	// MessagesDispatched is the number of messages that were handed to the dispatcher,
+	// counting each of the messages in a bundle.
+	MessagesDispatched uint64
+
+	// Unmatched is the number of dispatched messages that did not match any handler.
+	// It is only counted for PatternMatching and FuncMatching dispatchers.
+	Unmatched uint64
+}
+
+// connStats holds the counters of a conn.
+// They are updated atomically, so that counting does not need a lock or allocate.
+type connStats struct {
+	packetsReceived    uint64
+	parseErrors        uint64
+	messagesDispatched uint64
+	unmatched          uint64
+}
+
+// snapshot returns the current values of the counters.
+func (s *connStats) snapshot() Stats {
+	return Stats{
+		PacketsReceived:    atomic.LoadUint64(&s.packetsReceived),
+		ParseErrors:        atomic.LoadUint64(&s.parseErrors),
+		MessagesDispatched: atomic.LoadUint64(&s.messagesDispatched),
+		Unmatched:          atomic.LoadUint64(&s.unmatched),
+	}
+}
+
+// countMessages returns the number of messages in a bundle, including those of nested bundles.
+func countMessages(b Bundle) uint64 {
+	var n uint64
+	for _, p := range b.Packets {
+		switch x := p.(type) {
+		case Message:
+			n++
+		case Bundle:
+			n += countMessages(x)
+		}
+	}
+	return n
+}
@@ -0,0 +1,281 @@
+package osc
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TCPConn is an OSC connection over TCP.
+// TCP is a stream protocol, so every packet is framed
+// with an int32 size prefix as described in the OSC 1.0 spec.
+type TCPConn struct {
+	net.Conn
+
+	addressSchema *regexp.Regexp
+	closeChan     chan struct{}
+	closeOnce     sync.Once
+	ctx           context.Context
+	exactMatch    bool
+}
+
+// DialTCP creates a new OSC connection over TCP.
+func DialTCP(network string, laddr, raddr *net.TCPAddr) (*TCPConn, error) {
+	return DialTCPContext(context.Background(), network, laddr, raddr)
+}
+
+// DialTCPContext returns a new OSC connection over TCP that can be canceled with the provided context.
+func DialTCPContext(ctx context.Context, network string, laddr, raddr *net.TCPAddr) (*TCPConn, error) {
+	conn, err := net.DialTCP(network, laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return newTCPConn(ctx, conn, false), nil
+}
+
+// newTCPConn wraps a TCP connection.
+func newTCPConn(ctx context.Context, conn net.Conn, exactMatch bool) *TCPConn {
+	return &TCPConn{
+		Conn:       conn,
+		closeChan:  make(chan struct{}),
+		ctx:        ctx,
+		exactMatch: exactMatch,
+	}
+}
+
+// Close closes the tcp conn.
+// It is safe to call Close more than once.
+func (conn *TCPConn) Close() error {
+	err := net.ErrClosed
+	conn.closeOnce.Do(func() {
+		close(conn.closeChan)
+		err = conn.Conn.Close()
+	})
+	return err
+}
+
+// CloseChan returns a channel that is closed when the connection gets closed.
+func (conn *TCPConn) CloseChan() <-chan struct{} {
+	return conn.closeChan
+}
+
+// Context returns the context associated with the conn.
+func (conn *TCPConn) Context() context.Context {
+	return conn.ctx
+}
+
+// read reads the next size-prefixed packet and returns the net.Addr of the sender.
+func (conn *TCPConn) read(data []byte) (int, net.Addr, error) {
+	var size int32
+	if err := binary.Read(conn.Conn, byteOrder, &size); err != nil {
+		return 0, nil, err
+	}
+	if size < 0 || int(size) > len(data) {
+		return 0, nil, errors.Errorf("packet size %d does not fit in a %d byte buffer", size, len(data))
+	}
+	if _, err := io.ReadFull(conn.Conn, data[:size]); err != nil {
+		return 0, nil, errors.Wrap(err, "read packet")
+	}
+	return int(size), conn.RemoteAddr(), nil
+}
+
+// Send sends an OSC packet over TCP.
+func (conn *TCPConn) Send(p Packet) error {
+	var (
+		b     = p.Bytes()
+		frame = make([]byte, 4+len(b))
+	)
+	byteOrder.PutUint32(frame, uint32(len(b)))
+	copy(frame[4:], b)
+
+	// Write the size and the packet with a single call so that
+	// concurrent senders can not interleave their frames.
+	_, err := conn.Write(frame)
+	return err
+}
+
+// Serve starts dispatching OSC.
+// Any errors returned from a dispatched method will be returned.
+// Note that this means that errors returned from a dispatcher method will kill your server.
+// Serve returns nil when the remote end closes the connection.
+// If context.Canceled or context.DeadlineExceeded are encountered they will be returned directly.
+func (conn *TCPConn) Serve(numWorkers int, dispatcher Dispatcher) error {
+	err := serve(conn, numWorkers, dispatcher, serveOptions{
+		addressSchema: conn.addressSchema,
+		exactMatch:    conn.exactMatch,
+	})
+	if errors.Cause(err) == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// SetContext sets the context associated with the conn.
+func (conn *TCPConn) SetContext(ctx context.Context) {
+	conn.ctx = ctx
+}
+
+// SetAddressSchema makes Serve reject handlers whose addresses do not match the provided regular expression.
+// This can be used to enforce an addressing convention, e.g. ^/[a-z]+(/[a-z0-9]+)*$
+// Passing nil disables the check.
+func (conn *TCPConn) SetAddressSchema(re *regexp.Regexp) {
+	conn.addressSchema = re
+}
+
+// SetExactMatch changes the behavior of the Serve method so that
+// messages will only be dispatched to methods whose addresses
+// match the message's address exactly.
+// This should provide some performance improvement.
+func (conn *TCPConn) SetExactMatch(value bool) {
+	conn.exactMatch = value
+}
+
+// TCPListener accepts OSC connections over TCP.
+type TCPListener struct {
+	ln *net.TCPListener
+
+	addressSchema *regexp.Regexp
+	ctx           context.Context
+	exactMatch    bool
+}
+
+// ListenTCP creates a new TCP server.
+func ListenTCP(network string, laddr *net.TCPAddr) (*TCPListener, error) {
+	return ListenTCPContext(context.Background(), network, laddr)
+}
+
+// ListenTCPContext creates a TCP listener that can be canceled with the provided context.
+func ListenTCPContext(ctx context.Context, network string, laddr *net.TCPAddr) (*TCPListener, error) {
+	ln, err := net.ListenTCP(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &TCPListener{ln: ln, ctx: ctx}, nil
+}
+
+// Accept waits for the next connection.
+func (l *TCPListener) Accept() (*TCPConn, error) {
+	conn, err := l.ln.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	c := newTCPConn(l.ctx, conn, l.exactMatch)
+	c.addressSchema = l.addressSchema
+	return c, nil
+}
+
+// Addr returns the listener's network address.
+func (l *TCPListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops listening.
+func (l *TCPListener) Close() error {
+	return l.ln.Close()
+}
+
+// Context returns the context associated with the listener.
+func (l *TCPListener) Context() context.Context {
+	return l.ctx
+}
+
+// Serve accepts connections and dispatches the OSC they receive,
+// using numWorkers workers for each connection.
+// Serve returns nil when the listener is closed.
+// Any errors returned from a dispatched method will be returned,
+// and all the connections that were accepted get closed.
+// Connections accepted after Serve has returned are closed right away,
+// but the listener stays open until Close is called.
+// If context.Canceled or context.DeadlineExceeded are encountered they will be returned directly.
+func (l *TCPListener) Serve(numWorkers int, dispatcher Dispatcher) error {
+	if err := checkDispatcher(dispatcher, l.addressSchema); err != nil {
+		return err
+	}
+	var (
+		errChan = make(chan error, 1)
+		mu      sync.Mutex
+		conns   = map[*TCPConn]struct{}{}
+		stopped bool // Serve has returned, so accepted connections must be closed right away.
+	)
+	fail := func(err error) {
+		select {
+		case errChan <- err:
+		default:
+		}
+	}
+	defer func() {
+		mu.Lock()
+		stopped = true
+		for conn := range conns {
+			_ = conn.Close() // Best effort.
+		}
+		mu.Unlock()
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					err = nil
+				}
+				fail(err)
+				return
+			}
+			mu.Lock()
+			if stopped {
+				mu.Unlock()
+				_ = conn.Close() // Best effort.
+				return
+			}
+			conns[conn] = struct{}{}
+			mu.Unlock()
+
+			go func() {
+				err := conn.Serve(numWorkers, dispatcher)
+
+				mu.Lock()
+				_, open := conns[conn]
+				delete(conns, conn)
+				mu.Unlock()
+
+				if err != nil && open {
+					fail(err)
+				}
+			}()
+		}
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-l.ctx.Done():
+		return l.ctx.Err()
+	}
+}
+
+// SetContext sets the context associated with the listener.
+// Connections that are accepted afterwards inherit the context.
+func (l *TCPListener) SetContext(ctx context.Context) {
+	l.ctx = ctx
+}
+
+// SetAddressSchema makes Serve reject handlers whose addresses do not match the provided regular expression.
+// This can be used to enforce an addressing convention, e.g. ^/[a-z]+(/[a-z0-9]+)*$
+// Passing nil disables the check.
+func (l *TCPListener) SetAddressSchema(re *regexp.Regexp) {
+	l.addressSchema = re
+}
+
+// SetExactMatch changes the behavior of the Serve method so that
+// messages will only be dispatched to methods whose addresses
+// match the message's address exactly.
+// This should provide some performance improvement.
+func (l *TCPListener) SetExactMatch(value bool) {
+	l.exactMatch = value
+}
@@ -0,0 +1,215 @@
+package osc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTCPServer(t *testing.T, dispatcher PatternMatching) (*TCPListener, *TCPConn, chan error) {
+	laddr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenTCP("tcp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errChan := make(chan error)
+	go func() {
+		if err := server.Serve(1, dispatcher); err != nil {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	raddr, err := net.ResolveTCPAddr("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := DialTCP("tcp", nil, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, conn, errChan
+}
+
+func TestTCPConnSendMany(t *testing.T) {
+	const numMessages = 5
+
+	received := make(chan Message, numMessages)
+
+	server, conn, errChan := testTCPServer(t, PatternMatching{
+		"/foo": Method(func(msg Message) error {
+			received <- msg
+			return nil
+		}),
+	})
+	// Send messages of different sizes back-to-back over the same connection.
+	for i := 0; i < numMessages; i++ {
+		msg := Message{
+			Address:   "/foo",
+			Arguments: Arguments{Int(i), String(strings.Repeat("a", i*3))},
+		}
+		if err := conn.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < numMessages; i++ {
+		select {
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for message %d", i)
+		case msg := <-received:
+			expected := Message{
+				Address:   "/foo",
+				Arguments: Arguments{Int(i), String(strings.Repeat("a", i*3))},
+			}
+			if !expected.Equal(msg) {
+				t.Fatalf("expected %+v, got %+v", expected, msg)
+			}
+			if msg.Sender == nil {
+				t.Fatal("expected sender to be set")
+			}
+		}
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTCPConnSendBundle(t *testing.T) {
+	done := make(chan struct{})
+
+	server, conn, errChan := testTCPServer(t, PatternMatching{
+		"/bar": Method(func(msg Message) error {
+			close(done)
+			return nil
+		}),
+	})
+	defer func() { _ = server.Close() }() // Best effort.
+
+	b := Bundle{
+		Timetag: FromTime(time.Now()),
+		Packets: []Packet{Message{Address: "/bar"}},
+	}
+	if err := conn.Send(b); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	case <-done:
+	}
+}
+
+func TestTCPConnServe_BadPacket(t *testing.T) {
+	server, conn, errChan := testTCPServer(t, PatternMatching{})
+	defer func() { _ = server.Close() }() // Best effort.
+
+	if err := conn.Send(badPacket{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestTCPConnCloseTwice(t *testing.T) {
+	server, conn, _ := testTCPServer(t, PatternMatching{})
+	defer func() { _ = server.Close() }() // Best effort.
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestDialTCP(t *testing.T) {
+	if _, err := DialTCP("asdfiauosweif", nil, nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	if _, err := ListenTCP("asdfiauosweif", nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestTCPListenerServe_NilDispatcher(t *testing.T) {
+	laddr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenTCP("tcp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	if err := server.Serve(1, nil); err != ErrNilDispatcher {
+		t.Fatalf("expected ErrNilDispatcher, got %+v", err)
+	}
+}
+
+func TestTCPListenerServeClosesLateConns(t *testing.T) {
+	laddr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server, err := ListenTCPContext(ctx, "tcp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	serveErrs := make(chan error, 1)
+	go func() {
+		serveErrs <- server.Serve(1, PatternMatching{})
+	}()
+	cancel()
+
+	select {
+	case err := <-serveErrs:
+		if expected, got := context.Canceled, err; expected != got {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+	// A connection that is accepted after Serve returned gets closed.
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
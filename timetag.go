@@ -3,6 +3,8 @@ package osc
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/pkg/errors"
@@ -12,7 +14,7 @@ const (
 	// SecondsFrom1900To1970 is exactly what it sounds like.
 	SecondsFrom1900To1970 = 2208988800 // Source: RFC 868
 
-	nanosecondsPerFraction = float64(0.23283064365386962891) // 1e9/(2^32)
+	nanosecondsPerSecond = uint64(time.Second)
 
 	// TimetagSize is the number of 8-bit bytes in an OSC timetag.
 	TimetagSize = 8
@@ -38,6 +40,7 @@ func (tt Timetag) Bytes() []byte {
 	return bs
 }
 
+// String converts the timetag to a string.
 func (tt Timetag) String() string {
 	return tt.Time().Format(time.RFC3339)
 }
@@ -53,10 +56,17 @@ func (tt Timetag) Time() time.Time {
 		return time.Time{}
 	}
 
-	return time.Unix(
-		int64(t>>32)-SecondsFrom1900To1970,
-		int64(nanosecondsPerFraction*float64(t&(1<<32-1))),
-	).UTC()
+	// The fraction is in units of 1/2^32 seconds.
+	// Rounding down here and up in FromTime makes time.Time values round-trip exactly.
+	nsec := ((t & (1<<32 - 1)) * nanosecondsPerSecond) >> 32
+
+	return time.Unix(int64(t>>32)-SecondsFrom1900To1970, int64(nsec)).UTC()
+}
+
+// NewTimetag converts the given time to an OSC timetag.
+// The zero time is converted to Immediately.
+func NewTimetag(t time.Time) Timetag {
+	return FromTime(t)
 }
 
 // FromTime converts the given time to an OSC timetag.
@@ -64,10 +74,54 @@ func FromTime(t time.Time) Timetag {
 	if t.IsZero() {
 		return 1
 	}
+	var (
+		seconds  = uint64(t.Unix() + SecondsFrom1900To1970)
+		fraction = (uint64(t.Nanosecond())<<32 + nanosecondsPerSecond - 1) / nanosecondsPerSecond
+	)
+	return Timetag((seconds << 32) + fraction)
+}
+
+// TimetagFromUint64 creates a timetag from its raw 64-bit NTP representation.
+func TimetagFromUint64(v uint64) Timetag {
+	return Timetag(v)
+}
+
+// Uint64 returns the raw 64-bit NTP representation of the timetag:
+// seconds since 1900 in the high 32 bits and the fraction of a second in the low 32 bits.
+func (tt Timetag) Uint64() uint64 {
+	return uint64(tt)
+}
+
+// Timetags are also OSC arguments, with the type tag 't'.
+
+// Equal returns true if the argument equals the other one, false otherwise.
+func (tt Timetag) Equal(other Argument) bool {
+	tt2, ok := other.(Timetag)
+	return ok && tt == tt2
+}
+
+// ReadInt32 reads a 32-bit integer from the arg.
+func (tt Timetag) ReadInt32() (int32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadFloat32 reads a 32-bit float from the arg.
+func (tt Timetag) ReadFloat32() (float32, error) { return 0, ErrInvalidTypeTag }
+
+// ReadBool reads a boolean from the arg.
+func (tt Timetag) ReadBool() (bool, error) { return false, ErrInvalidTypeTag }
+
+// ReadString reads a string from the arg.
+func (tt Timetag) ReadString() (string, error) { return "", ErrInvalidTypeTag }
+
+// ReadBlob reads a slice of bytes from the arg.
+func (tt Timetag) ReadBlob() ([]byte, error) { return nil, ErrInvalidTypeTag }
+
+// Typetag returns the argument's type tag.
+func (tt Timetag) Typetag() byte { return TypetagTimetag }
 
-	seconds := uint64(t.Unix() + SecondsFrom1900To1970)
-	secondFraction := float64(t.UTC().Nanosecond()) / nanosecondsPerFraction
-	return Timetag((seconds << 32) + uint64(uint32(secondFraction)))
+// WriteTo writes the arg to an io.Writer.
+func (tt Timetag) WriteTo(w io.Writer) (int64, error) {
+	written, err := fmt.Fprint(w, tt.String())
+	return int64(written), err
 }
 
 // ReadTimetag parses a timetag from a byte slice.
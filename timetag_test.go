@@ -28,6 +28,28 @@ func TestFromTime(t *testing.T) {
 	}
 }
 
+func TestNewTimetagRoundTrip(t *testing.T) {
+	for _, input := range []time.Time{
+		time.Date(2017, time.March, 4, 12, 30, 15, 0, time.UTC),
+		time.Date(2017, time.March, 4, 12, 30, 15, 1, time.UTC),
+		time.Date(2017, time.March, 4, 12, 30, 15, 500000000, time.UTC),
+		time.Date(2017, time.March, 4, 12, 30, 15, 999999999, time.UTC),
+		time.Unix(0, 123456789),
+	} {
+		if expected, got := input, NewTimetag(input).Time(); !expected.Equal(got) {
+			t.Fatalf("expected %s, got %s", expected.Format(time.RFC3339Nano), got.Format(time.RFC3339Nano))
+		}
+	}
+	// Half a second is exactly 2^31 fractions.
+	tt := NewTimetag(time.Unix(0, 500000000))
+	if expected, got := uint64(SecondsFrom1900To1970)<<32+1<<31, tt.Uint64(); expected != got {
+		t.Fatalf("expected %#x, got %#x", expected, got)
+	}
+	if expected, got := Immediately, NewTimetag(time.Time{}); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}
+
 func TestTimetagString(t *testing.T) {
 	for _, testcase := range []struct {
 		Input    Timetag
@@ -84,3 +106,22 @@ func TestReadTimetag(t *testing.T) {
 		}
 	}
 }
+
+func TestTimetagUint64(t *testing.T) {
+	for _, v := range []uint64{0, 1, 0xDEADBEEF00000000, 0x83AA7E8000000001, 1<<64 - 1} {
+		tt := TimetagFromUint64(v)
+		if expected, got := v, tt.Uint64(); expected != got {
+			t.Fatalf("expected %x, got %x", expected, got)
+		}
+		parsed, err := ReadTimetag(tt.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := v, parsed.Uint64(); expected != got {
+			t.Fatalf("expected %x, got %x", expected, got)
+		}
+	}
+	if expected, got := Immediately, TimetagFromUint64(1); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}
@@ -3,6 +3,9 @@ package osc
 import (
 	"context"
 	"net"
+	"regexp"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -19,10 +22,21 @@ type udpConn interface {
 type UDPConn struct {
 	udpConn
 
-	closeChan  chan struct{}
-	ctx        context.Context
-	errChan    chan error
-	exactMatch bool
+	addressSchema *regexp.Regexp
+	closeChan     chan struct{}
+	closeOnce     sync.Once
+	ctx           context.Context
+	errChan       chan error
+	errorHandler  func(error)
+	errorReply    bool
+	exactMatch    bool
+	inFlight      sync.WaitGroup
+	maxPacketSize int
+	rateLimiter   *rateLimiter
+	readBufSize   int
+	senderFilter  func(net.Addr) bool
+	splitBundles  bool
+	stats         connStats
 }
 
 // DialUDP creates a new OSC connection over UDP.
@@ -66,9 +80,14 @@ func ListenUDPContext(ctx context.Context, network string, laddr *net.UDPAddr) (
 }
 
 // Close closes the udp conn.
+// Closing a conn that is already closed returns net.ErrClosed.
 func (conn *UDPConn) Close() error {
-	close(conn.closeChan)
-	return conn.udpConn.Close()
+	err := net.ErrClosed
+	conn.closeOnce.Do(func() {
+		close(conn.closeChan)
+		err = conn.udpConn.Close()
+	})
+	return err
 }
 
 // CloseChan returns a channel that is closed when the connection gets closed.
@@ -95,24 +114,200 @@ func (conn *UDPConn) read(data []byte) (int, net.Addr, error) {
 	return conn.ReadFromUDP(data)
 }
 
+// ReadPacket reads a single datagram and parses it.
+// It returns the packet, which is either a Message or a Bundle, and the address of the sender.
+// This is an alternative to Serve for simple request/response clients
+// and must not be used on a conn that is being served.
+func (conn *UDPConn) ReadPacket() (Packet, net.Addr, error) {
+	data := make([]byte, conn.ReadBufferSize())
+	n, sender, err := conn.ReadFromUDP(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := parsePacket(data[:n], sender)
+	if err != nil {
+		return nil, sender, errors.Wrap(err, "parse packet")
+	}
+	return p, sender, nil
+}
+
+// ReadPacketContext is like ReadPacket, but it gives up when ctx is done
+// and returns the context's error.
+func (conn *UDPConn) ReadPacketContext(ctx context.Context) (Packet, net.Addr, error) {
+	var (
+		stop    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = conn.SetReadDeadline(time.Now()) // Interrupt the read.
+		case <-stop:
+		}
+	}()
+	p, sender, err := conn.ReadPacket()
+	close(stop)
+	<-stopped
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// Clear the deadline so that it does not affect the next read.
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			return nil, nil, errors.Wrap(err, "clear read deadline")
+		}
+		if err != nil {
+			return nil, nil, ctxErr
+		}
+	}
+	return p, sender, err
+}
+
 // Send sends an OSC message over UDP.
+// It is safe to call Send and SendTo from multiple goroutines:
+// serializing a packet does not modify it, and each
+// packet is written with a single call to the underlying conn.
+// See SetMaxPacketSize for what happens to packets that are too large.
 func (conn *UDPConn) Send(p Packet) error {
-	_, err := conn.Write(p.Bytes())
-	return err
+	return conn.send(p, func(data []byte) error {
+		_, err := conn.Write(data)
+		return err
+	})
 }
 
 // SendTo sends a packet to the given address.
+// If the conn was dialed, the address it was dialed with can be used too.
 func (conn *UDPConn) SendTo(addr net.Addr, p Packet) error {
-	_, err := conn.WriteTo(p.Bytes(), addr)
+	return conn.send(p, func(data []byte) error {
+		return conn.writeTo(data, addr)
+	})
+}
+
+// writeTo writes data to addr.
+// A conn that was dialed can not use WriteTo, so data for its remote address is written with Write.
+func (conn *UDPConn) writeTo(data []byte, addr net.Addr) error {
+	if raddr := conn.RemoteAddr(); raddr != nil && raddr.String() == addr.String() {
+		_, err := conn.Write(data)
+		return err
+	}
+	_, err := conn.WriteTo(data, addr)
 	return err
 }
 
+// send writes a packet with write, splitting it or failing if it is larger than the maximum packet size.
+func (conn *UDPConn) send(p Packet, write func([]byte) error) error {
+	data := p.Bytes()
+	if conn.maxPacketSize <= 0 || len(data) <= conn.maxPacketSize {
+		return write(data)
+	}
+	b, ok := p.(Bundle)
+	if !ok || !conn.splitBundles {
+		return errors.Wrapf(ErrPacketTooLarge, "packet is %d bytes, the maximum is %d", len(data), conn.maxPacketSize)
+	}
+	bundles, err := b.Split(conn.maxPacketSize)
+	if err != nil {
+		return errors.Wrap(err, "split bundle")
+	}
+	for _, b := range bundles {
+		if err := write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendBundle sends a bundle.
+// It is the same as Send, but the compiler checks that b is a bundle.
+func (conn *UDPConn) SendBundle(b Bundle) error {
+	return conn.Send(b)
+}
+
+// SendBundleTo sends a bundle to the given address.
+func (conn *UDPConn) SendBundleTo(addr net.Addr, b Bundle) error {
+	return conn.SendTo(addr, b)
+}
+
+// Broadcast sends a packet to every one of the provided addresses the way SendTo does,
+// with at most concurrency goroutines at a time (if concurrency <= 0 one goroutine is used).
+// The returned slice has an entry for each address,
+// which is nil if sending to that address succeeded.
+func (conn *UDPConn) Broadcast(addrs []net.Addr, p Packet, concurrency int) []error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		errs = make([]error, len(addrs))
+		idxs = make(chan int)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < concurrency && i < len(addrs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range idxs {
+				if err := conn.SendTo(addrs[idx], p); err != nil {
+					errs[idx] = errors.Wrapf(err, "send to %s", addrs[idx])
+				}
+			}
+		}()
+	}
+	for i := range addrs {
+		idxs <- i
+	}
+	close(idxs)
+	wg.Wait()
+
+	return errs
+}
+
 // Serve starts dispatching OSC.
 // Any errors returned from a dispatched method will be returned.
 // Note that this means that errors returned from a dispatcher method will kill your server.
 // If context.Canceled or context.DeadlineExceeded are encountered they will be returned directly.
 func (conn *UDPConn) Serve(numWorkers int, dispatcher Dispatcher) error {
-	return serve(conn, numWorkers, conn.exactMatch, dispatcher)
+	opts := serveOptions{
+		addressSchema: conn.addressSchema,
+		errorHandler:  conn.errorHandler,
+		exactMatch:    conn.exactMatch,
+		inFlight:      &conn.inFlight,
+		rateLimiter:   conn.rateLimiter,
+		readTimeout:   readTimeout,
+		senderFilter:  conn.senderFilter,
+		stats:         &conn.stats,
+		readBufSize:   conn.readBufSize,
+	}
+	if conn.errorReply {
+		opts.errorReply = conn
+	}
+	return serve(conn, numWorkers, dispatcher, opts)
+}
+
+// ReadBufferSize returns the size of the buffer that Serve reads each packet into.
+// Packets that are larger than this will be truncated.
+func (conn *UDPConn) ReadBufferSize() int {
+	if conn.readBufSize <= 0 {
+		return bufSize
+	}
+	return conn.readBufSize
+}
+
+// Shutdown closes the conn and then waits for the packets that have already been
+// read by Serve to finish being dispatched.
+// If ctx is done before that happens, Shutdown returns the context's error.
+func (conn *UDPConn) Shutdown(ctx context.Context) error {
+	if err := conn.Close(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		conn.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // SetContext sets the context associated with the conn.
@@ -120,6 +315,82 @@ func (conn *UDPConn) SetContext(ctx context.Context) {
 	conn.ctx = ctx
 }
 
+// SetMaxPacketSize makes Send and SendTo refuse to send packets that are larger than n bytes,
+// e.g. the MTU of the network, with an error whose cause is ErrPacketTooLarge
+// instead of leaving it to the network to drop or fragment them.
+// See SetSplitBundles for sending large bundles anyway.
+// If n <= 0 packets of any size are sent.
+func (conn *UDPConn) SetMaxPacketSize(n int) {
+	conn.maxPacketSize = n
+}
+
+// SetSplitBundles changes the behavior of Send and SendTo so that bundles that are larger than
+// the maximum packet size (see SetMaxPacketSize) are split into several bundles that fit, see Bundle.Split.
+// The bundles are sent one after the other, so with UDP some of them may be lost.
+func (conn *UDPConn) SetSplitBundles(value bool) {
+	conn.splitBundles = value
+}
+
+// SetReadBufferSize sets the size of the buffer that Serve reads each packet into.
+// It must be called before Serve.
+// If n <= 0 the default size of 64K is used.
+func (conn *UDPConn) SetReadBufferSize(n int) {
+	conn.readBufSize = n
+}
+
+// SetAddressSchema makes Serve reject handlers whose addresses do not match the provided regular expression.
+// This can be used to enforce an addressing convention, e.g. ^/[a-z]+(/[a-z0-9]+)*$
+// Passing nil disables the check.
+func (conn *UDPConn) SetAddressSchema(re *regexp.Regexp) {
+	conn.addressSchema = re
+}
+
+// SetErrorHandler changes the behavior of the Serve method so that errors that
+// only affect a single packet (e.g. a packet that can not be parsed, or an error
+// returned from the dispatcher) are passed to fn and Serve keeps running.
+// Serve still returns errors that affect the connection itself.
+// Passing nil restores the default behavior.
+func (conn *UDPConn) SetErrorHandler(fn func(error)) {
+	conn.errorHandler = fn
+}
+
+// SetRateLimit changes the behavior of the Serve method so that each sender IP
+// may only send rate packets per second, with bursts of up to burst packets.
+// Packets over the limit are dropped before they are parsed and, if there is an error handler
+// (see SetErrorHandler), reported to it with an error whose cause is ErrRateLimited.
+// Passing a rate that is not positive disables rate limiting.
+func (conn *UDPConn) SetRateLimit(rate float64, burst int) {
+	if rate <= 0 {
+		conn.rateLimiter = nil
+		return
+	}
+	conn.rateLimiter = newRateLimiter(rate, burst)
+}
+
+// Stats returns a snapshot of the counters of the packets that Serve has received.
+// It is safe to call Stats while Serve is running.
+func (conn *UDPConn) Stats() Stats {
+	return conn.stats.snapshot()
+}
+
+// SetSenderFilter changes the behavior of the Serve method so that only packets from
+// senders that allow returns true for are handled, e.g. to only accept OSC from trusted hosts
+// (see AllowCIDRs). Other packets are dropped before they are parsed and, if there is an
+// error handler (see SetErrorHandler), reported to it with an error whose cause is ErrSenderRejected.
+// Passing nil accepts packets from every sender.
+func (conn *UDPConn) SetSenderFilter(allow func(net.Addr) bool) {
+	conn.senderFilter = allow
+}
+
+// SetErrorReply changes the behavior of the Serve method so that errors
+// returned from the dispatcher are sent back to the sender of the packet,
+// instead of making Serve return.
+// The error reply is sent to ErrorAddress and its arguments are
+// the address of the message that failed (#bundle for bundles) and the error text.
+func (conn *UDPConn) SetErrorReply(value bool) {
+	conn.errorReply = value
+}
+
 // SetExactMatch changes the behavior of the Serve method so that
 // messages will only be dispatched to methods whose addresses
 // match the message's address exactly.
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"net"
+	"regexp"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -30,6 +33,130 @@ func TestInvalidAddress(t *testing.T) {
 	}
 }
 
+func TestUDPConnAddressSchema(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetAddressSchema(regexp.MustCompile(`^/[a-z]+(/[a-z0-9]+)*$`))
+
+	err = server.Serve(1, PatternMatching{
+		"/mixer/ch1/gain": Method(func(msg Message) error {
+			return nil
+		}),
+		"/mixer/Ch1/gain": Method(func(msg Message) error {
+			return nil
+		}),
+	})
+	if errors.Cause(err) != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %+v", err)
+	}
+	if expected, got := "/mixer/Ch1/gain does not match address schema ^/[a-z]+(/[a-z0-9]+)*$: invalid OSC address", err.Error(); expected != got {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestUDPConnAddressSchemaFuncMatching(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetAddressSchema(regexp.MustCompile(`^/[a-z]+$`))
+
+	for _, address := range []string{"/Bad", "no-slash["} {
+		err := server.Serve(1, FuncMatching{
+			PatternMatching: PatternMatching{
+				address: Method(func(msg Message) error { return nil }),
+			},
+			MatchFunc: func(msgAddr, handlerAddr string) bool { return true },
+		})
+		if errors.Cause(err) != ErrInvalidAddress {
+			t.Fatalf("(%s) expected ErrInvalidAddress, got %+v", address, err)
+		}
+	}
+}
+
+func TestUDPConnServeNoLeak(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errChan := make(chan error)
+	go func() {
+		errChan <- server.Serve(4, PatternMatching{})
+	}()
+	// Give Serve a moment to start up its goroutines.
+	time.Sleep(20 * time.Millisecond)
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at most %d goroutines, got %d", baseline, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUDPConnServeCancel(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := ListenUDPContext(ctx, "udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %+v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
+
 func TestDialUDP(t *testing.T) {
 	if _, err := DialUDP("asdfiauosweif", nil, nil); err == nil {
 		t.Fatal("expected error, got nil")
@@ -283,6 +410,51 @@ func TestUDPConnSendBundle(t *testing.T) {
 	}
 }
 
+func TestUDPConnSendBundleReadBack(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	b := NewBundle(FromTime(time.Now().Add(time.Second)),
+		Message{Address: "/synth/new", Arguments: Arguments{String("sine"), Int(1000)}},
+		Message{Address: "/synth/set", Arguments: Arguments{Int(1000), Float(440)}},
+	)
+	if err := client.SendBundle(b); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := server.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", b, got)
+	}
+
+	// Reply to the client.
+	if err := server.SendBundleTo(client.LocalAddr(), b); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err = client.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(got) {
+		t.Fatalf("expected %+v, got %+v", b, got)
+	}
+}
+
 func TestUDPConnSendBundle_BadTypetag(t *testing.T) {
 	_, conn, errChan := testUDPServer(t, nil)
 	if err := conn.Send(badBundle{}); err != nil {
@@ -358,3 +530,1111 @@ func (bb badBundle) Bytes() []byte {
 func (bb badBundle) Equal(other Packet) bool {
 	return false
 }
+
+func TestUDPConnReadBufferSize(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	if expected, got := bufSize, server.ReadBufferSize(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	// A UDP datagram can not be larger than 64K, so the best we can do
+	// is raise the buffer size and send a packet close to that limit.
+	var (
+		blob     = bytes.Repeat([]byte{0xAB}, 60000)
+		msg      = Message{Address: "/blob", Arguments: Arguments{Blob(blob)}}
+		received = make(chan Message)
+		errChan  = make(chan error, 1)
+	)
+	server.SetReadBufferSize(1024)
+
+	if expected, got := 1024, server.ReadBufferSize(); expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+	server.SetReadBufferSize(bufSize * 2)
+
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{
+			"/blob": Method(func(msg Message) error {
+				received <- msg
+				return nil
+			}),
+		})
+	}()
+	raddr, err := net.ResolveUDPAddr("udp", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := DialUDP("udp", nil, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	case got := <-received:
+		b, err := got.Arguments[0].ReadBlob()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(blob, b) {
+			t.Fatalf("expected a %d byte blob, got %d bytes", len(blob), len(b))
+		}
+	}
+}
+
+func TestUDPConnErrorReply(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetErrorReply(true)
+
+	errChan := make(chan error, 2)
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{
+			"/fail": Method(func(msg Message) error {
+				return errors.New("boom")
+			}),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	replies := make(chan Message, 2)
+	go func() {
+		errChan <- client.Serve(1, PatternMatching{
+			ErrorAddress: Method(func(msg Message) error {
+				replies <- msg
+				return nil
+			}),
+		})
+	}()
+	// The server keeps serving after a handler fails, so both messages get a reply.
+	for i := 0; i < 2; i++ {
+		if err := client.Send(Message{Address: "/fail"}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case reply := <-replies:
+			expected := Message{
+				Address:   ErrorAddress,
+				Arguments: Arguments{String("/fail"), String("dispatch message: boom")},
+			}
+			if !expected.Equal(reply) {
+				t.Fatalf("expected %+v, got %+v", expected, reply)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for error reply")
+		}
+	}
+}
+
+func TestUDPConnReadPacket(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	for _, p := range []Packet{
+		Message{Address: "/status", Arguments: Arguments{Int(1)}},
+		Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/status"}}},
+	} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+		got, sender, err := server.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Equal(got) {
+			t.Fatalf("expected %+v, got %+v", p, got)
+		}
+		if expected, got := client.LocalAddr().String(), sender.String(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	// Garbage that is not OSC.
+	if _, err := client.Write([]byte{'x', 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := server.ReadPacket(); errors.Cause(err) != ErrParse {
+		t.Fatalf("expected ErrParse, got %+v", err)
+	}
+}
+
+func TestUDPConnServeEmptyDispatcher(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errChan := make(chan error)
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	for _, p := range []Packet{
+		Message{Address: "/foo"},
+		Message{Address: "/bar", Arguments: Arguments{Int(1)}},
+		Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/baz"}}},
+	} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing matches, so the packets are dropped without an error.
+	select {
+	case err := <-errChan:
+		t.Fatalf("expected Serve to keep running, got %+v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUDPConnSendConcurrent(t *testing.T) {
+	const (
+		numSenders = 8
+		numSends   = 25
+	)
+	var (
+		received = make(chan struct{}, numSenders*numSends)
+		errChan  = make(chan error, numSenders+1)
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	go func() {
+		errChan <- server.Serve(4, PatternMatching{
+			"/mixer/gain": Method(func(msg Message) error {
+				received <- struct{}{}
+				return nil
+			}),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	// All the senders share one bundle, so serializing it concurrently must be safe.
+	shared := Bundle{
+		Timetag: Immediately,
+		Packets: []Packet{
+			Message{Address: "/mixer/gain", Arguments: Arguments{Float(0.5), Blob{1, 2, 3}}},
+		},
+	}
+	for i := 0; i < numSenders; i++ {
+		go func() {
+			for j := 0; j < numSends; j++ {
+				if err := client.Send(shared); err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}()
+	}
+	timeout := time.After(2 * time.Second)
+
+	for i := 0; i < numSenders*numSends; i++ {
+		select {
+		case <-received:
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout after receiving %d messages", i)
+		}
+	}
+}
+
+func TestUDPConnShutdownDeadline(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		errChan = make(chan error, 1)
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{
+			"/slow": Method(func(msg Message) error {
+				close(started)
+				<-release
+				return nil
+			}),
+		})
+	}()
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(Message{Address: "/slow"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for handler to start")
+	}
+	// The handler is blocked, so a short deadline expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %+v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+}
+
+func TestUDPConnShutdownWaits(t *testing.T) {
+	var (
+		started  = make(chan struct{})
+		finished = make(chan struct{})
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(1, PatternMatching{
+			"/slow": Method(func(msg Message) error {
+				close(started)
+				time.Sleep(50 * time.Millisecond)
+				close(finished)
+				return nil
+			}),
+		})
+	}()
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(Message{Address: "/slow"}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("expected Shutdown to wait for the handler")
+	}
+}
+
+func TestUDPConnShutdownClose(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed, got %v", err)
+	}
+	if err := server.Shutdown(context.Background()); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed, got %v", err)
+	}
+}
+
+func TestUDPConnErrorHandler(t *testing.T) {
+	var (
+		errs    = make(chan error, 2)
+		msgChan = make(chan Message, 1)
+		errChan = make(chan error, 1)
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetErrorHandler(func(err error) {
+		errs <- err
+	})
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{
+			"/valid": Method(func(msg Message) error {
+				msgChan <- msg
+				return nil
+			}),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	// A message with no type tags, followed by a valid one.
+	if _, err := client.Write([]byte{'/', 'f', 'o', 'o', 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(Message{Address: "/valid"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if errors.Cause(err) != ErrParse {
+			t.Fatalf("expected ErrParse, got %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for error handler")
+	}
+	select {
+	case msg := <-msgChan:
+		if expected, got := "/valid", msg.Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestUDPConnReceivedAt(t *testing.T) {
+	msgChan := make(chan Message, 2)
+
+	server, conn, errChan := testUDPServer(t, PatternMatching{
+		"/stamp": Method(func(msg Message) error {
+			msgChan <- msg
+			return nil
+		}),
+	})
+	defer func() { _ = server.Close() }() // Best effort.
+
+	before := time.Now()
+	for _, p := range []Packet{
+		Message{Address: "/stamp"},
+		Bundle{Timetag: Immediately, Packets: []Packet{Message{Address: "/stamp"}}},
+	} {
+		if err := conn.Send(p); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-msgChan:
+			if at := msg.ReceivedAt(); at.Before(before) || at.After(time.Now()) {
+				t.Fatalf("expected receive time between %s and now, got %s", before, at)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+}
+
+func TestUDPConnTruncatedBlob(t *testing.T) {
+	errs := make(chan error, 1)
+
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetReadBufferSize(64)
+	server.SetErrorHandler(func(err error) {
+		errs <- err
+	})
+	go func() {
+		_ = server.Serve(1, PatternMatching{
+			"/sample": Method(func(msg Message) error {
+				return nil
+			}),
+		})
+	}()
+	client, err := NewClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(Message{Address: "/sample", Arguments: Arguments{Blob(make([]byte, 100))}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if errors.Cause(err) != ErrParse {
+			t.Fatalf("expected ErrParse, got %+v", err)
+		}
+		expected := "packet filled the 64 byte read buffer and was probably truncated: " +
+			"parse message: read argument 0: truncated blob: size 100 needs 100 bytes with padding, 48 remaining: " +
+			"error parsing message"
+		if got := err.Error(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+}
+
+func TestUDPConnServePattern(t *testing.T) {
+	msgChan := make(chan Message, 1)
+
+	server, conn, errChan := testUDPServer(t, PatternMatching{
+		"/ch/1/mute": Method(func(msg Message) error {
+			msgChan <- msg
+			return nil
+		}),
+	})
+	defer func() { _ = server.Close() }() // Best effort.
+
+	// Incoming addresses are patterns, so they may contain reserved characters.
+	if err := conn.Send(Message{Address: "/ch/*/mute"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgChan:
+		if expected, got := "/ch/*/mute", msg.Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestUDPConnServeRoot(t *testing.T) {
+	msgChan := make(chan Message, 1)
+
+	server, conn, errChan := testUDPServer(t, PatternMatching{
+		"/": Method(func(msg Message) error {
+			msgChan <- msg
+			return nil
+		}),
+	})
+	defer func() { _ = server.Close() }() // Best effort.
+
+	if err := conn.Send(Message{Address: "/", Arguments: Arguments{Int(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgChan:
+		if expected, got := 0, len(msg.AddressSegments()); expected != got {
+			t.Fatalf("expected %d segments, got %d", expected, got)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestUDPConnBroadcast(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		addrs     = []net.Addr{}
+		listeners = []*UDPConn{}
+	)
+	for i := 0; i < 8; i++ {
+		l, err := ListenUDP("udp", laddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = l.Close() }() // Best effort.
+
+		addrs = append(addrs, l.LocalAddr())
+		listeners = append(listeners, l)
+	}
+	conn, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	msg := Message{Address: "/broadcast", Arguments: Arguments{Int(1)}}
+
+	for i, err := range conn.Broadcast(addrs, msg, 3) {
+		if err != nil {
+			t.Fatalf("(address %d) %s", i, err)
+		}
+	}
+	for i, l := range listeners {
+		if err := l.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		p, _, err := l.ReadPacket()
+		if err != nil {
+			t.Fatalf("(listener %d) %s", i, err)
+		}
+		if !msg.Equal(p) {
+			t.Fatalf("(listener %d) expected %+v, got %+v", i, msg, p)
+		}
+	}
+}
+
+func TestUDPConnBroadcastMaxPacketSize(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	conn.SetMaxPacketSize(16)
+
+	addrs := []net.Addr{conn.LocalAddr(), conn.LocalAddr()}
+	msg := Message{Address: "/broadcast", Arguments: Arguments{String("too large for the maximum")}}
+
+	for i, err := range conn.Broadcast(addrs, msg, 2) {
+		if errors.Cause(err) != ErrPacketTooLarge {
+			t.Fatalf("(address %d) expected ErrPacketTooLarge, got %v", i, err)
+		}
+	}
+}
+
+func TestUDPConnBroadcastDialed(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	conn, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }() // Best effort.
+
+	msg := Message{Address: "/broadcast"}
+
+	for i, err := range conn.Broadcast([]net.Addr{server.LocalAddr()}, msg, 1) {
+		if err != nil {
+			t.Fatalf("(address %d) %s", i, err)
+		}
+	}
+	if err := server.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := server.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Equal(p) {
+		t.Fatalf("expected %+v, got %+v", msg, p)
+	}
+}
+
+func TestUDPConnRateLimit(t *testing.T) {
+	const (
+		burst = 5
+		flood = 50
+	)
+	var (
+		mu       sync.Mutex
+		received = map[string]int{}
+		dropped  = make(chan error, flood)
+		errChan  = make(chan error, 1)
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetRateLimit(1, burst)
+	server.SetErrorHandler(func(err error) {
+		dropped <- err
+	})
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{
+			"/count": Method(func(msg Message) error {
+				mu.Lock()
+				received[msg.SenderIP().String()]++
+				mu.Unlock()
+				return nil
+			}),
+		})
+	}()
+	raddr := server.LocalAddr().(*net.UDPAddr)
+
+	flooder, err := DialUDPContext(context.Background(), "udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = flooder.Close() }() // Best effort.
+
+	// The limit is per IP, so the other sender needs a different loopback address.
+	other, err := DialUDPContext(context.Background(), "udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2)}, raddr)
+	if err != nil {
+		t.Skipf("can not bind to 127.0.0.2: %s", err)
+	}
+	defer func() { _ = other.Close() }() // Best effort.
+
+	for i := 0; i < flood; i++ {
+		if err := flooder.Send(Message{Address: "/count"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < burst; i++ {
+		if err := other.Send(Message{Address: "/count"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// At least flood-burst-1 packets are dropped (one more may fit in if a token is refilled).
+	timeout := time.After(2 * time.Second)
+	for i := 0; i < flood-burst-1; i++ {
+		select {
+		case err := <-dropped:
+			if errors.Cause(err) != ErrRateLimited {
+				t.Fatalf("expected ErrRateLimited, got %+v", err)
+			}
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout after %d dropped packets", i)
+		}
+	}
+	// All of the other sender's packets get through.
+	for {
+		mu.Lock()
+		got := received["127.0.0.2"]
+		mu.Unlock()
+
+		if got == burst {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("expected %d packets from the other sender, got %d", burst, got)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if got := received["127.0.0.1"]; got < burst || got > burst+1 {
+		t.Fatalf("expected %d or %d packets from the flooder, got %d", burst, burst+1, got)
+	}
+}
+
+func TestUDPConnSenderFilter(t *testing.T) {
+	var (
+		msgChan  = make(chan Message, 2)
+		rejected = make(chan error, 2)
+		errChan  = make(chan error, 1)
+	)
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	allow, err := AllowCIDRs("127.0.0.1/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetSenderFilter(allow)
+	server.SetErrorHandler(func(err error) {
+		rejected <- err
+	})
+	go func() {
+		errChan <- server.Serve(1, PatternMatching{
+			"/hello": Method(func(msg Message) error {
+				msgChan <- msg
+				return nil
+			}),
+		})
+	}()
+	raddr := server.LocalAddr().(*net.UDPAddr)
+
+	untrusted, err := DialUDPContext(context.Background(), "udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2)}, raddr)
+	if err != nil {
+		t.Skipf("can not bind to 127.0.0.2: %s", err)
+	}
+	defer func() { _ = untrusted.Close() }() // Best effort.
+
+	trusted, err := DialUDPContext(context.Background(), "udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = trusted.Close() }() // Best effort.
+
+	if err := untrusted.Send(Message{Address: "/hello"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-rejected:
+		if errors.Cause(err) != ErrSenderRejected {
+			t.Fatalf("expected ErrSenderRejected, got %+v", err)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the untrusted packet to be rejected")
+	}
+	if err := trusted.Send(Message{Address: "/hello"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgChan:
+		if expected, got := "127.0.0.1", msg.SenderIP().String(); expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case err := <-errChan:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the trusted message")
+	}
+	if len(msgChan) > 0 {
+		t.Fatal("expected the untrusted message to be dropped")
+	}
+}
+
+func TestUDPConnStats(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	server.SetErrorHandler(func(err error) {})
+	go func() {
+		_ = server.Serve(1, PatternMatching{
+			"/valid": Method(func(msg Message) error { return nil }),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	for _, p := range []Packet{
+		Message{Address: "/valid"},
+		Message{Address: "/unmatched"},
+		NewBundle(Immediately, Message{Address: "/valid"}, Message{Address: "/unmatched"}),
+	} {
+		if err := client.Send(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.Write([]byte{'x', 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{
+		PacketsReceived:    4,
+		ParseErrors:        1,
+		MessagesDispatched: 4,
+		Unmatched:          2,
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		got := server.Stats()
+		if expected == got {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestUDPConnHandlerContext(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := ListenUDPContext(ctx, "udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	var (
+		started = make(chan struct{})
+		aborted = make(chan error, 1)
+	)
+	go func() {
+		_ = server.Serve(1, PatternMatching{
+			"/long": ContextMethod(func(ctx context.Context, msg Message) error {
+				close(started)
+				<-ctx.Done()
+				aborted <- ctx.Err()
+				return nil
+			}),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(Message{Address: "/long"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the handler to start")
+	}
+	cancel()
+
+	select {
+	case err := <-aborted:
+		if expected, got := context.Canceled, err; expected != got {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the handler's context to be done")
+	}
+}
+
+func TestUDPConnHandlerPanic(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	var (
+		errs    = make(chan error, 1)
+		handled = make(chan Message, 1)
+	)
+	server.SetErrorHandler(func(err error) { errs <- err })
+
+	go func() {
+		_ = server.Serve(1, PatternMatching{
+			"/panic": Method(func(msg Message) error { panic("oops") }),
+			"/ok": Method(func(msg Message) error {
+				handled <- msg
+				return nil
+			}),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if err := client.Send(Message{Address: "/panic"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if expected, got := ErrHandlerPanic, errors.Cause(err); expected != got {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the panic to be reported")
+	}
+	// The only worker has to be back in the pool for this to be handled.
+	if err := client.Send(Message{Address: "/ok"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-handled:
+		if expected, got := "/ok", msg.Address; expected != got {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the server to keep serving")
+	}
+}
+
+func TestUDPConnMaxPacketSize(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	client.SetMaxPacketSize(1500)
+
+	huge := Message{Address: "/huge", Arguments: Arguments{Blob(make([]byte, 2000))}}
+	if err := client.Send(huge); errors.Cause(err) != ErrPacketTooLarge {
+		t.Fatalf("expected ErrPacketTooLarge, got %v", err)
+	}
+	msg := Message{Address: "/part", Arguments: Arguments{Blob(make([]byte, 1000))}}
+	if err := client.Send(NewBundle(Immediately, msg, msg)); errors.Cause(err) != ErrPacketTooLarge {
+		t.Fatalf("expected ErrPacketTooLarge, got %v", err)
+	}
+	// With splitting the bundle is sent as two bundles.
+	client.SetSplitBundles(true)
+
+	if err := client.Send(NewBundle(Immediately, msg, msg)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		p, _, err := server.ReadPacketContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := NewBundle(Immediately, msg), p; !expected.Equal(got) {
+			t.Fatalf("(bundle %d) expected %+v, got %+v", i, expected, got)
+		}
+	}
+	if err := client.Send(huge); errors.Cause(err) != ErrPacketTooLarge {
+		t.Fatalf("expected ErrPacketTooLarge, got %v", err)
+	}
+}
+
+func TestUDPConnEmptyPacket(t *testing.T) {
+	laddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }() // Best effort.
+
+	serveErrs := make(chan error, 1)
+	go func() {
+		serveErrs <- server.Serve(1, PatternMatching{
+			"/foo": Method(func(msg Message) error { return nil }),
+		})
+	}()
+	client, err := DialUDPContext(context.Background(), "udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }() // Best effort.
+
+	if _, err := client.Write([]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-serveErrs:
+		if expected, got := ErrParse, errors.Cause(err); expected != got {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
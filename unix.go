@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"regexp"
 
 	ulid "github.com/imdario/go-ulid"
 	"github.com/pkg/errors"
@@ -21,10 +22,13 @@ type unixConn interface {
 type UnixConn struct {
 	unixConn
 
-	closeChan  chan struct{}
-	ctx        context.Context
-	errChan    chan error
-	exactMatch bool
+	addressSchema *regexp.Regexp
+	closeChan     chan struct{}
+	ctx           context.Context
+	errChan       chan error
+	errorHandler  func(error)
+	errorReply    bool
+	exactMatch    bool
 }
 
 // DialUnix opens a unix socket for OSC communication.
@@ -112,7 +116,16 @@ func (conn *UnixConn) SendTo(addr net.Addr, p Packet) error {
 // Note that this means that errors returned from a dispatcher method will kill your server.
 // If context.Canceled or context.DeadlineExceeded are encountered they will be returned directly.
 func (conn *UnixConn) Serve(numWorkers int, dispatcher Dispatcher) error {
-	return serve(conn, numWorkers, conn.exactMatch, dispatcher)
+	opts := serveOptions{
+		addressSchema: conn.addressSchema,
+		errorHandler:  conn.errorHandler,
+		exactMatch:    conn.exactMatch,
+		readTimeout:   readTimeout,
+	}
+	if conn.errorReply {
+		opts.errorReply = conn
+	}
+	return serve(conn, numWorkers, dispatcher, opts)
 }
 
 // TempSocket creates an absolute path to a temporary socket file.
@@ -120,6 +133,31 @@ func TempSocket() string {
 	return filepath.Join(os.TempDir(), ulid.New().String()) + ".sock"
 }
 
+// SetAddressSchema makes Serve reject handlers whose addresses do not match the provided regular expression.
+// This can be used to enforce an addressing convention, e.g. ^/[a-z]+(/[a-z0-9]+)*$
+// Passing nil disables the check.
+func (conn *UnixConn) SetAddressSchema(re *regexp.Regexp) {
+	conn.addressSchema = re
+}
+
+// SetErrorHandler changes the behavior of the Serve method so that errors that
+// only affect a single packet (e.g. a packet that can not be parsed, or an error
+// returned from the dispatcher) are passed to fn and Serve keeps running.
+// Serve still returns errors that affect the connection itself.
+// Passing nil restores the default behavior.
+func (conn *UnixConn) SetErrorHandler(fn func(error)) {
+	conn.errorHandler = fn
+}
+
+// SetErrorReply changes the behavior of the Serve method so that errors
+// returned from the dispatcher are sent back to the sender of the packet,
+// instead of making Serve return.
+// The error reply is sent to ErrorAddress and its arguments are
+// the address of the message that failed (#bundle for bundles) and the error text.
+func (conn *UnixConn) SetErrorReply(value bool) {
+	conn.errorReply = value
+}
+
 // SetExactMatch changes the behavior of the Serve method so that
 // messages will only be dispatched to methods whose addresses
 // match the message's address exactly.
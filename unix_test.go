@@ -144,3 +144,55 @@ func TestUnixSendTo(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUnixExchange(t *testing.T) {
+	addr, err := net.ResolveUnixAddr("unixgram", TempSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ListenUnix("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverErrs := make(chan error)
+	go func() {
+		if err := server.Serve(1, PatternMatching{
+			"/ping": Method(func(m Message) error {
+				return server.SendTo(m.Sender, Message{Address: "/pong"})
+			}),
+		}); err != nil {
+			serverErrs <- err
+		}
+		close(serverErrs)
+	}()
+	pongch := make(chan struct{})
+
+	client, clientErrs := tmpListener(t, PatternMatching{
+		"/pong": Method(func(m Message) error {
+			close(pongch)
+			return nil
+		}),
+	})
+	if err := client.SendTo(server.LocalAddr(), Message{Address: "/ping"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-serverErrs:
+		t.Fatal(err)
+	case err := <-clientErrs:
+		t.Fatal(err)
+	case <-time.After(1 * time.Second):
+		t.Fatal("timeout")
+	case <-pongch:
+	}
+	for _, conn := range []*UnixConn{server, client} {
+		if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, errChan := range []chan error{serverErrs, clientErrs} {
+		if err := <-errChan; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package osc
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrArgumentCount    = errors.New("wrong number of arguments")
+	ErrInvalidUnmarshal = errors.New("unmarshal requires a non-nil pointer to a struct")
+)
+
+// Unmarshal reads the message's arguments into the struct that v points to.
+//
+// By default the exported fields of the struct are mapped to the arguments in order.
+// The mapping can be changed with the "osc" field tag, which is a comma-separated list of options:
+//
+//	index=N   the field is read from argument N, and the fields after it continue from N+1
+//	optional  the field is left alone if the message does not have the argument
+//	-         the field is ignored
+//
+// Fields can be int32 (and the other integer kinds), float32 or float64, bool, string or []byte,
+// and have to match the type of the argument they are read from.
+// It is an error for the message to have arguments that no field is read from,
+// and the cause of that error is ErrArgumentCount.
+// A missing argument is an error whose cause is ErrIndexOutOfBounds,
+// and an argument of the wrong type is an error whose cause is ErrInvalidTypeTag.
+func (msg Message) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.Wrapf(ErrInvalidUnmarshal, "got %T", v)
+	}
+	var (
+		sv     = rv.Elem()
+		st     = sv.Type()
+		idx    = 0
+		mapped = 0
+	)
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" {
+			continue // Unexported.
+		}
+		tag, err := parseFieldTag(field.Tag.Get("osc"))
+		if err != nil {
+			return errors.Wrapf(err, "field %s", field.Name)
+		}
+		if tag.skip {
+			continue
+		}
+		if tag.index >= 0 {
+			idx = tag.index
+		}
+		if idx >= len(msg.Arguments) {
+			if tag.optional {
+				idx++
+				continue
+			}
+			return errors.Wrapf(ErrIndexOutOfBounds, "field %s: message has no argument %d", field.Name, idx)
+		}
+		if err := setField(sv.Field(i), msg.Arguments[idx]); err != nil {
+			return errors.Wrapf(err, "field %s (argument %d)", field.Name, idx)
+		}
+		if idx+1 > mapped {
+			mapped = idx + 1
+		}
+		idx++
+	}
+	if mapped < len(msg.Arguments) {
+		return errors.Wrapf(ErrArgumentCount, "message has %d arguments, %T only maps %d", len(msg.Arguments), v, mapped)
+	}
+	return nil
+}
+
+// MarshalMessage creates a message whose arguments are the exported fields of the struct v,
+// or the struct v points to, in the order they are declared.
+// Fields are converted the same way AppendMap converts values, and fields of
+// other integer and float kinds become Int and Float.
+// Fields whose "osc" field tag is "-" are left out.
+// The index option of the tag is not supported, since the arguments are always in field order.
+func MarshalMessage(addr string, v interface{}) (*Message, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.Wrapf(ErrUnsupportedType, "marshal requires a struct, got %T", v)
+	}
+	var (
+		st  = rv.Type()
+		msg = &Message{Address: addr}
+	)
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" {
+			continue // Unexported.
+		}
+		tag, err := parseFieldTag(field.Tag.Get("osc"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s", field.Name)
+		}
+		if tag.skip {
+			continue
+		}
+		if tag.index >= 0 {
+			return nil, errors.Errorf("field %s: the index option is not supported by MarshalMessage", field.Name)
+		}
+		arg, err := fieldArgument(rv.Field(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s", field.Name)
+		}
+		msg.Arguments = append(msg.Arguments, arg)
+	}
+	return msg, nil
+}
+
+// fieldArgument converts a struct field to an argument.
+func fieldArgument(field reflect.Value) (Argument, error) {
+	if arg, ok := field.Interface().(Argument); ok {
+		return arg, nil
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := field.Int()
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, errors.Wrapf(ErrUnsupportedType, "%d overflows int32", i)
+		}
+		return toArgument(int32(i))
+	case reflect.Float32, reflect.Float64:
+		return toArgument(field.Float())
+	case reflect.Bool:
+		return toArgument(field.Bool())
+	case reflect.String:
+		return toArgument(field.String())
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			return toArgument(append([]byte{}, field.Bytes()...))
+		}
+	}
+	return nil, errors.Wrapf(ErrUnsupportedType, "%s", field.Type())
+}
+
+// fieldTag holds the options of an "osc" field tag.
+type fieldTag struct {
+	index    int
+	optional bool
+	skip     bool
+}
+
+// parseFieldTag parses an "osc" field tag.
+func parseFieldTag(s string) (fieldTag, error) {
+	tag := fieldTag{index: -1}
+	if s == "-" {
+		tag.skip = true
+		return tag, nil
+	}
+	for _, opt := range strings.Split(s, ",") {
+		switch {
+		case opt == "":
+		case opt == "optional":
+			tag.optional = true
+		case strings.HasPrefix(opt, "index="):
+			idx, err := strconv.Atoi(strings.TrimPrefix(opt, "index="))
+			if err != nil || idx < 0 {
+				return tag, errors.Errorf("invalid osc tag option %q", opt)
+			}
+			tag.index = idx
+		default:
+			return tag, errors.Errorf("unknown osc tag option %q", opt)
+		}
+	}
+	return tag, nil
+}
+
+// setField sets a struct field from an argument.
+func setField(field reflect.Value, arg Argument) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := arg.ReadInt32()
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(i))
+	case reflect.Float32, reflect.Float64:
+		f, err := arg.ReadFloat32()
+		if err != nil {
+			return err
+		}
+		field.SetFloat(float64(f))
+	case reflect.Bool:
+		b, err := arg.ReadBool()
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.String:
+		s, err := arg.ReadString()
+		if err != nil {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return errors.Wrapf(ErrUnsupportedType, "%s", field.Type())
+		}
+		b, err := arg.ReadBlob()
+		if err != nil {
+			return err
+		}
+		field.SetBytes(b)
+	default:
+		return errors.Wrapf(ErrUnsupportedType, "%s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package osc
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestMessageUnmarshal(t *testing.T) {
+	type Fade struct {
+		Channel int32
+		Level   float32
+		Curve   string `osc:"optional"`
+	}
+	var fade Fade
+
+	msg := Message{Address: "/fade", Arguments: Arguments{Int(2), Float(0.5)}}
+	if err := msg.Unmarshal(&fade); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (Fade{Channel: 2, Level: 0.5}), fade; expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	msg.Arguments = append(msg.Arguments, String("exp"))
+
+	if err := msg.Unmarshal(&fade); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (Fade{Channel: 2, Level: 0.5, Curve: "exp"}), fade; expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMessageUnmarshalIndex(t *testing.T) {
+	type Note struct {
+		Velocity int    `osc:"index=2"`
+		Name     string `osc:"index=0"`
+		Pitch    int32
+		Ignored  bool `osc:"-"`
+		ignored  bool
+	}
+	var (
+		note Note
+		msg  = Message{Address: "/note", Arguments: Arguments{String("a"), Int(69), Int(100)}}
+	)
+	if err := msg.Unmarshal(&note); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (Note{Name: "a", Pitch: 69, Velocity: 100}), note; expected != got {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMessageUnmarshalError(t *testing.T) {
+	type Fade struct {
+		Channel int32
+		Level   float32
+	}
+	for i, testcase := range []struct {
+		Message  Message
+		V        interface{}
+		Expected string
+	}{
+		{
+			Message:  Message{Address: "/fade"},
+			V:        Fade{},
+			Expected: "got osc.Fade: unmarshal requires a non-nil pointer to a struct",
+		},
+		{
+			Message:  Message{Address: "/fade", Arguments: Arguments{Int(1)}},
+			V:        &Fade{},
+			Expected: "field Level: message has no argument 1: index out of bounds",
+		},
+		{
+			Message:  Message{Address: "/fade", Arguments: Arguments{Int(1), String("loud")}},
+			V:        &Fade{},
+			Expected: "field Level (argument 1): invalid type tag",
+		},
+		{
+			Message:  Message{Address: "/fade", Arguments: Arguments{Int(1), Float(1), Float(2)}},
+			V:        &Fade{},
+			Expected: "message has 3 arguments, *osc.Fade only maps 2: wrong number of arguments",
+		},
+		{
+			Message: Message{Address: "/fade", Arguments: Arguments{Int(1)}},
+			V: &struct {
+				Channel int32 `osc:"index=x"`
+			}{},
+			Expected: `field Channel: invalid osc tag option "index=x"`,
+		},
+		{
+			Message: Message{Address: "/fade", Arguments: Arguments{Int(1)}},
+			V: &struct {
+				Channel []int32
+			}{},
+			Expected: "field Channel (argument 0): []int32: unsupported type",
+		},
+	} {
+		err := testcase.Message.Unmarshal(testcase.V)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Expected, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+	err := Message{Address: "/fade"}.Unmarshal(nil)
+	if expected, got := ErrInvalidUnmarshal, errors.Cause(err); expected != got {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestMessageUnmarshalErrorCause(t *testing.T) {
+	type Fade struct {
+		Channel int32
+		Level   float32
+	}
+	for i, testcase := range []struct {
+		Message  Message
+		Expected error
+	}{
+		{
+			Message:  Message{Address: "/fade", Arguments: Arguments{Int(1)}},
+			Expected: ErrIndexOutOfBounds,
+		},
+		{
+			Message:  Message{Address: "/fade", Arguments: Arguments{Float(1), Float(0.5)}},
+			Expected: ErrInvalidTypeTag,
+		},
+		{
+			Message:  Message{Address: "/fade", Arguments: Arguments{Int(1), Float(0.5), Int(3)}},
+			Expected: ErrArgumentCount,
+		},
+	} {
+		var fade Fade
+		if expected, got := testcase.Expected, errors.Cause(testcase.Message.Unmarshal(&fade)); expected != got {
+			t.Fatalf("(testcase %d) expected %v, got %v", i, expected, got)
+		}
+	}
+}
+
+func TestMarshalMessage(t *testing.T) {
+	type Level float32
+
+	type Fade struct {
+		Channel int32
+		Level   Level
+		Curve   string
+		Loop    bool
+		Data    []byte
+		Step    int8
+		At      Timetag
+		Ignored bool `osc:"-"`
+		ignored bool
+	}
+	fade := Fade{Channel: 2, Level: 0.5, Curve: "exp", Loop: true, Data: []byte{1}, Step: -1, At: Immediately, Ignored: true}
+
+	for _, v := range []interface{}{fade, &fade} {
+		msg, err := MarshalMessage("/fade", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Message{
+			Address:   "/fade",
+			Arguments: Arguments{Int(2), Float(0.5), String("exp"), Bool(true), Blob([]byte{1}), Int(-1), Immediately},
+		}
+		if !expected.Equal(*msg) {
+			t.Fatalf("expected %+v, got %+v", expected, *msg)
+		}
+	}
+}
+
+func TestMarshalMessageError(t *testing.T) {
+	for i, testcase := range []struct {
+		V        interface{}
+		Expected string
+	}{
+		{
+			V:        1,
+			Expected: "marshal requires a struct, got int: unsupported type",
+		},
+		{
+			V: struct {
+				Channel int64
+			}{Channel: 1 << 40},
+			Expected: "field Channel: 1099511627776 overflows int32: unsupported type",
+		},
+		{
+			V: struct {
+				Channels []int32
+			}{},
+			Expected: "field Channels: []int32: unsupported type",
+		},
+		{
+			V: struct {
+				Channel int32 `osc:"index=1"`
+			}{},
+			Expected: "field Channel: the index option is not supported by MarshalMessage",
+		},
+	} {
+		_, err := MarshalMessage("/fade", testcase.V)
+		if err == nil {
+			t.Fatalf("(testcase %d) expected error, got nil", i)
+		}
+		if expected, got := testcase.Expected, err.Error(); expected != got {
+			t.Fatalf("(testcase %d) expected %s, got %s", i, expected, got)
+		}
+	}
+}
@@ -1,53 +1,238 @@
 package osc
 
 import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+
 	"github.com/pkg/errors"
 )
 
+// ErrorAddress is the address that error replies are sent to.
+// See SetErrorReply.
+const ErrorAddress = "/error"
+
+// sendToer can send a packet to an address.
+type sendToer interface {
+	SendTo(net.Addr, Packet) error
+}
+
 // worker is a worker who can process OSC messages.
 type worker struct {
+	Context    context.Context
 	DataChan   chan Incoming
 	Dispatcher Dispatcher
+	Done       <-chan struct{}
 	ErrChan    chan error
 	Ready      chan<- worker
 	ExactMatch bool
+
+	// ErrorReply, if it is not nil, is used to tell the sender
+	// about errors returned from the dispatcher instead of
+	// sending them on the error chan.
+	ErrorReply sendToer
+
+	// InFlight, if it is not nil, has been incremented
+	// for every Incoming that is sent on the data chan.
+	// The worker marks each one done once it has been handled.
+	InFlight *sync.WaitGroup
+
+	// ErrorHandler, if it is not nil, is called with errors
+	// that only affect a single packet instead of sending
+	// them on the error chan, so the worker keeps running.
+	ErrorHandler func(error)
+
+	// Stats, if it is not nil, counts parse errors and dispatched messages.
+	Stats *connStats
 }
 
 // run runs the worker.
+// The worker exits when the data chan or the done chan is closed.
 func (w worker) run() {
-	w.Ready <- w
-
-DataLoop:
-	for incoming := range w.DataChan {
-		data := incoming.Data
+	if !w.ready() {
+		return
+	}
+	for {
+		var incoming Incoming
 
-		switch data[0] {
-		case BundleTag[0]:
-			bundle, err := ParseBundle(data, incoming.Sender)
-			if err != nil {
-				w.ErrChan <- err
+		select {
+		case in, ok := <-w.DataChan:
+			if !ok {
+				return
 			}
-			if err := w.Dispatcher.Dispatch(bundle, w.ExactMatch); err != nil {
-				w.ErrChan <- errors.Wrap(err, "dispatch bundle")
+			incoming = in
+		case <-w.Done:
+			return
+		}
+		again := w.handleRecover(incoming)
+
+		if incoming.release != nil {
+			incoming.release()
+		}
+		if w.InFlight != nil {
+			w.InFlight.Done()
+		}
+		if !again {
+			continue
+		}
+		// Announce the worker is ready again.
+		if !w.ready() {
+			return
+		}
+	}
+}
+
+// handleRecover is like handle, but it converts a panic in a handler into an error
+// whose cause is ErrHandlerPanic, so that one bad handler does not take the worker down.
+// The error is handled like any other packet error.
+func (w worker) handleRecover(incoming Incoming) (again bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			again = w.packetFailed(errors.Wrapf(ErrHandlerPanic, "%v", r))
+		}
+	}()
+	return w.handle(incoming)
+}
+
+// handle parses and dispatches incoming data.
+// It returns false if the worker should not announce that it is ready again.
+func (w worker) handle(incoming Incoming) bool {
+	data := incoming.Data
+	if len(data) == 0 {
+		return w.parseFailed(incoming, errors.Wrap(ErrParse, "empty packet"))
+	}
+	switch data[0] {
+	case BundleTag[0]:
+		bundle, err := ParseBundle(data, incoming.Sender)
+		if err != nil {
+			return w.parseFailed(incoming, err)
+		}
+		bundle = stamp(bundle, incoming.ReceivedAt, w.Context).(Bundle)
+		if err := w.dispatch(bundle); err != nil {
+			if w.Context != nil && w.Context.Err() != nil {
+				return false // Serving has stopped, so the bundle was abandoned.
 			}
-		case MessageChar:
-			msg, err := ParseMessage(data, incoming.Sender)
-			if err != nil {
-				w.ErrChan <- err
-				continue DataLoop
+			if w.dispatchFailed(incoming.Sender, string(BundleTag), errors.Wrap(err, "dispatch bundle")) {
+				return false
 			}
-			if err := ValidateAddress(msg.Address); err != nil {
-				w.ErrChan <- err
-				continue DataLoop
+		}
+	case MessageChar:
+		msg, err := ParseMessage(data, incoming.Sender)
+		if err != nil {
+			return w.parseFailed(incoming, err)
+		}
+		msg = stamp(msg, incoming.ReceivedAt, w.Context).(Message)
+		if err := w.invoke(msg); err != nil {
+			if w.dispatchFailed(incoming.Sender, msg.Address, errors.Wrap(err, "dispatch message")) {
+				return false
 			}
-			if err := w.Dispatcher.Invoke(msg, w.ExactMatch); err != nil {
-				w.ErrChan <- errors.Wrap(err, "dispatch message")
-				continue DataLoop
+		}
+	default:
+		return w.parseFailed(incoming, errors.Wrapf(ErrParse, "packet should never start with %c", data[0]))
+	}
+	return true
+}
+
+// invoke dispatches a message, counting it if the worker has stats.
+func (w worker) invoke(msg Message) error {
+	if w.Stats == nil {
+		return w.Dispatcher.Invoke(msg, w.ExactMatch)
+	}
+	atomic.AddUint64(&w.Stats.messagesDispatched, 1)
+
+	cd, ok := w.countingDispatcher()
+	if !ok {
+		return w.Dispatcher.Invoke(msg, w.ExactMatch)
+	}
+	matched, err := cd.invokeCounted(msg, w.ExactMatch)
+	if matched == 0 {
+		atomic.AddUint64(&w.Stats.unmatched, 1)
+	}
+	return err
+}
+
+// dispatch dispatches a bundle.
+// If the dispatcher is a ContextDispatcher and the worker has a context,
+// the dispatcher gives up on the bundle when the context is done.
+func (w worker) dispatch(bundle Bundle) error {
+	if w.Stats != nil {
+		if _, ok := w.countingDispatcher(); ok {
+			// These dispatchers dispatch bundles by invoking their messages,
+			// so the worker does the same in order to count each of the messages.
+			ctx := w.Context
+			if ctx == nil {
+				ctx = context.Background()
 			}
-		default:
-			w.ErrChan <- ErrParse
+			return dispatchContext(ctx, bundle, w.invoke)
 		}
-		// Announce the worker is ready again.
-		w.Ready <- w
+		atomic.AddUint64(&w.Stats.messagesDispatched, countMessages(bundle))
+	}
+	if cd, ok := w.Dispatcher.(ContextDispatcher); ok && w.Context != nil {
+		return cd.DispatchContext(w.Context, bundle, w.ExactMatch)
+	}
+	return w.Dispatcher.Dispatch(bundle, w.ExactMatch)
+}
+
+// countingDispatcher returns the worker's dispatcher if it can count the handlers that match a message.
+func (w worker) countingDispatcher() (countingDispatcher, bool) {
+	return asCountingDispatcher(w.Dispatcher)
+}
+
+// dispatchFailed handles an error returned from the dispatcher.
+// If error replies are enabled the error is sent back to the sender
+// of the packet, otherwise it is handled like any other packet error.
+// It returns true if the error was fatal.
+func (w worker) dispatchFailed(sender net.Addr, address string, err error) bool {
+	if w.ErrorReply == nil || sender == nil {
+		return !w.packetFailed(err)
+	}
+	if err := w.ErrorReply.SendTo(sender, ErrorMessage(address, err.Error())); err != nil {
+		w.fail(errors.Wrap(err, "send error reply"))
+		return true
+	}
+	return false
+}
+
+// parseFailed handles an error parsing incoming data.
+func (w worker) parseFailed(incoming Incoming, err error) bool {
+	if w.Stats != nil {
+		atomic.AddUint64(&w.Stats.parseErrors, 1)
+	}
+	if incoming.truncated {
+		err = errors.Wrapf(err, "packet filled the %d byte read buffer and was probably truncated", len(incoming.Data))
+	}
+	return w.packetFailed(err)
+}
+
+// packetFailed handles an error that only affects a single packet.
+// If there is an error handler the error is passed to it and true is returned,
+// otherwise the error is sent on the error chan and false is returned.
+func (w worker) packetFailed(err error) bool {
+	if w.ErrorHandler == nil {
+		w.fail(err)
+		return false
+	}
+	w.ErrorHandler(err)
+	return true
+}
+
+// fail sends an error on the error chan.
+// It gives up if the done chan gets closed, since nobody is listening anymore.
+func (w worker) fail(err error) {
+	select {
+	case w.ErrChan <- err:
+	case <-w.Done:
+	}
+}
+
+// ready announces that the worker is ready for more data.
+// It returns false if the done chan gets closed first.
+func (w worker) ready() bool {
+	select {
+	case w.Ready <- w:
+		return true
+	case <-w.Done:
+		return false
 	}
 }
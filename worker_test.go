@@ -61,3 +61,45 @@ func (d errorDispatcher) Dispatch(bundle Bundle, exactMatch bool) error {
 func (d errorDispatcher) Invoke(msg Message, exactMatch bool) error {
 	return errors.New("fake Invoke error")
 }
+
+func TestWorkerHandleBundle(t *testing.T) {
+	var (
+		errs       = []error{}
+		dispatcher = make(recordingDispatcher, 1)
+	)
+	wrk := worker{
+		Dispatcher:   dispatcher,
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	}
+	for i, b := range []Bundle{
+		NewBundle(Immediately, Message{Address: "/foo"}, Message{Address: "/bar"}),
+		NewBundle(Immediately, Message{Address: "/foo"}, NewBundle(Immediately, Message{Address: "/bar"})),
+	} {
+		if !wrk.handle(Incoming{Data: b.Bytes()}) {
+			t.Fatalf("(testcase %d) expected worker to keep going", i)
+		}
+		select {
+		case p := <-dispatcher:
+			if !b.Equal(p) {
+				t.Fatalf("(testcase %d) expected %+v, got %+v", i, b, p)
+			}
+		default:
+			t.Fatalf("(testcase %d) expected bundle to be dispatched", i)
+		}
+	}
+	if expected, got := 0, len(errs); expected != got {
+		t.Fatalf("expected %d errors, got %d", expected, got)
+	}
+	// A malformed bundle must not be dispatched.
+	if !wrk.handle(Incoming{Data: []byte("#bundlx\x00")}) {
+		t.Fatal("expected worker to keep going")
+	}
+	select {
+	case p := <-dispatcher:
+		t.Fatalf("expected malformed bundle not to be dispatched, got %+v", p)
+	default:
+	}
+	if expected, got := 1, len(errs); expected != got {
+		t.Fatalf("expected %d errors, got %d", expected, got)
+	}
+}
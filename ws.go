@@ -0,0 +1,453 @@
+package osc
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Common errors.
+var (
+	ErrWebSocket = errors.New("websocket protocol error")
+)
+
+// wsGUID is the GUID that RFC 6455 uses to compute Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// maxWSControlPayload is the largest payload a control frame may have.
+const maxWSControlPayload = 125
+
+// WSConn is an OSC connection over a WebSocket, e.g. to talk to a browser.
+// Every packet is sent in its own binary message.
+// The WebSocket protocol (RFC 6455) is implemented with the standard library.
+// Text messages and extensions are not supported.
+type WSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// client is true for the end of the connection that dialed,
+	// which has to mask the frames it sends.
+	client bool
+
+	addressSchema *regexp.Regexp
+	closeChan     chan struct{}
+	closeOnce     sync.Once
+	ctx           context.Context
+	exactMatch    bool
+	writeMu       sync.Mutex
+}
+
+// DialWS opens an OSC connection to a WebSocket server.
+// rawurl has to have the ws scheme, wss is not supported.
+// The connection can be canceled with the provided context.
+func DialWS(ctx context.Context, rawurl string) (*WSConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+	if u.Scheme != "ws" {
+		return nil, errors.Errorf("unsupported websocket scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := wsHandshake(ctx, conn, u)
+	if err != nil {
+		_ = conn.Close() // Best effort.
+		return nil, err
+	}
+	return ws, nil
+}
+
+// wsHandshake performs the client side of the opening handshake.
+func wsHandshake(ctx context.Context, conn net.Conn, u *url.URL) (*WSConn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "generate key")
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.EscapedPath(), RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Host:       u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline) // Best effort.
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, errors.Wrap(err, "write handshake")
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "read handshake")
+	}
+	_ = resp.Body.Close() // Best effort.
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, errors.Wrapf(ErrWebSocket, "handshake: unexpected status %s", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != wsAccept(key) {
+		return nil, errors.Wrapf(ErrWebSocket, "handshake: bad Sec-WebSocket-Accept %q", got)
+	}
+	return newWSConn(ctx, conn, br, true), nil
+}
+
+// UpgradeWS upgrades an HTTP request to an OSC connection over a WebSocket.
+// It is meant to be called by an http.Handler, which should then serve the connection:
+//
+//	http.HandleFunc("/osc", func(w http.ResponseWriter, r *http.Request) {
+//		conn, err := osc.UpgradeWS(w, r)
+//		if err != nil {
+//			return // UpgradeWS already replied with an error.
+//		}
+//		defer conn.Close()
+//		_ = conn.Serve(1, dispatcher)
+//	})
+//
+// If the request is not a valid WebSocket handshake a 400 Bad Request is written
+// and an error whose cause is ErrWebSocket is returned.
+// The connection inherits the context of the request.
+func UpgradeWS(w http.ResponseWriter, r *http.Request) (*WSConn, error) {
+	if err := checkWSRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		err := errors.Wrap(ErrWebSocket, "response writer can not be hijacked")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "hijack connection")
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"
+
+	if _, err := io.WriteString(conn, resp); err != nil {
+		_ = conn.Close() // Best effort.
+		return nil, errors.Wrap(err, "write handshake")
+	}
+	return newWSConn(r.Context(), conn, brw.Reader, false), nil
+}
+
+// checkWSRequest returns an error if r is not a WebSocket opening handshake.
+func checkWSRequest(r *http.Request) error {
+	switch {
+	case r.Method != http.MethodGet:
+		return errors.Wrapf(ErrWebSocket, "handshake: method %s is not GET", r.Method)
+	case !headerContains(r.Header, "Connection", "upgrade"):
+		return errors.Wrap(ErrWebSocket, "handshake: missing Connection: Upgrade")
+	case !headerContains(r.Header, "Upgrade", "websocket"):
+		return errors.Wrap(ErrWebSocket, "handshake: missing Upgrade: websocket")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		return errors.Wrapf(ErrWebSocket, "handshake: unsupported version %q", r.Header.Get("Sec-WebSocket-Version"))
+	case r.Header.Get("Sec-WebSocket-Key") == "":
+		return errors.Wrap(ErrWebSocket, "handshake: missing Sec-WebSocket-Key")
+	}
+	return nil
+}
+
+// headerContains returns true if one of the comma-separated tokens of the header is token,
+// ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsAccept computes the Sec-WebSocket-Accept value for a Sec-WebSocket-Key.
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// newWSConn wraps a connection that has completed the opening handshake.
+func newWSConn(ctx context.Context, conn net.Conn, br *bufio.Reader, client bool) *WSConn {
+	return &WSConn{
+		conn:      conn,
+		br:        br,
+		client:    client,
+		closeChan: make(chan struct{}),
+		ctx:       ctx,
+	}
+}
+
+// Close sends a close frame and closes the connection.
+// It is safe to call Close more than once.
+func (conn *WSConn) Close() error {
+	err := net.ErrClosed
+	conn.closeOnce.Do(func() {
+		close(conn.closeChan)
+		_ = conn.writeFrame(wsClose, nil) // Best effort.
+		err = conn.conn.Close()
+	})
+	return err
+}
+
+// CloseChan returns a channel that is closed when the connection gets closed.
+func (conn *WSConn) CloseChan() <-chan struct{} {
+	return conn.closeChan
+}
+
+// Context returns the context associated with the conn.
+func (conn *WSConn) Context() context.Context {
+	return conn.ctx
+}
+
+// LocalAddr returns the local network address.
+func (conn *WSConn) LocalAddr() net.Addr {
+	return conn.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (conn *WSConn) RemoteAddr() net.Addr {
+	return conn.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the deadline for reading from the underlying connection.
+func (conn *WSConn) SetReadDeadline(t time.Time) error {
+	return conn.conn.SetReadDeadline(t)
+}
+
+// read reads the next binary message and returns the net.Addr of the sender.
+// Pings are answered and pongs are ignored while waiting for the message.
+// When the other end closes the connection io.EOF is returned.
+func (conn *WSConn) read(data []byte) (int, net.Addr, error) {
+	var (
+		n       int
+		started bool
+	)
+	for {
+		fin, opcode, payload, err := conn.readFrame(data[n:])
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := conn.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, errors.Wrap(err, "write pong")
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			_ = conn.writeFrame(wsClose, nil) // Best effort.
+			return 0, nil, io.EOF
+		case wsBinary:
+			if started {
+				return 0, nil, errors.Wrap(ErrWebSocket, "binary frame in the middle of a fragmented message")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return 0, nil, errors.Wrap(ErrWebSocket, "continuation frame without a message")
+			}
+		case wsText:
+			return 0, nil, errors.Wrap(ErrWebSocket, "text messages are not supported")
+		default:
+			return 0, nil, errors.Wrapf(ErrWebSocket, "unknown opcode %#x", opcode)
+		}
+		n += len(payload)
+
+		if fin {
+			return n, conn.RemoteAddr(), nil
+		}
+	}
+}
+
+// readFrame reads a single frame.
+// The payload of data frames is read into buf and the payload of control frames
+// into a separate slice, so that control frames can arrive in the middle of a fragmented message.
+func (conn *WSConn) readFrame(buf []byte) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(conn.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.Wrap(ErrWebSocket, "reserved bits are set")
+	}
+	masked := header[1]&0x80 != 0
+	if masked == conn.client {
+		return false, 0, nil, errors.Wrapf(ErrWebSocket, "masked is %t, expected %t", masked, !conn.client)
+	}
+	size := uint64(header[1] & 0x7F)
+
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(conn.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(conn.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(conn.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	if opcode >= wsClose {
+		if !fin || size > maxWSControlPayload {
+			return false, 0, nil, errors.Wrap(ErrWebSocket, "invalid control frame")
+		}
+		buf = make([]byte, size)
+	}
+	if size > uint64(len(buf)) {
+		return false, 0, nil, errors.Errorf("message does not fit in a %d byte buffer", len(buf))
+	}
+	payload = buf[:size]
+	if _, err := io.ReadFull(conn.br, payload); err != nil {
+		return false, 0, nil, errors.Wrap(err, "read frame")
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single, final frame.
+func (conn *WSConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+
+	var maskBit byte
+	if conn.client {
+		maskBit = 0x80
+	}
+	switch size := len(payload); {
+	case size < 126:
+		frame = append(frame, maskBit|byte(size))
+	case size <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(size))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(size))
+	}
+	start := len(frame)
+
+	if conn.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return errors.Wrap(err, "generate mask")
+		}
+		frame = append(frame, mask[:]...)
+		start += 4
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	// Concurrent senders, and the pongs sent by the read loop, must not interleave their frames.
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+
+	_, err := conn.conn.Write(frame)
+	return err
+}
+
+// Send sends an OSC packet in a binary message.
+func (conn *WSConn) Send(p Packet) error {
+	return conn.writeFrame(wsBinary, p.Bytes())
+}
+
+// Serve starts dispatching OSC.
+// Any errors returned from a dispatched method will be returned.
+// Note that this means that errors returned from a dispatcher method will kill your server.
+// Serve returns nil when the remote end closes the connection.
+// If context.Canceled or context.DeadlineExceeded are encountered they will be returned directly.
+func (conn *WSConn) Serve(numWorkers int, dispatcher Dispatcher) error {
+	err := serve(conn, numWorkers, dispatcher, serveOptions{
+		addressSchema: conn.addressSchema,
+		exactMatch:    conn.exactMatch,
+	})
+	if errors.Cause(err) == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// SetContext sets the context associated with the conn.
+func (conn *WSConn) SetContext(ctx context.Context) {
+	conn.ctx = ctx
+}
+
+// SetAddressSchema makes Serve reject handlers whose addresses do not match the provided regular expression.
+// This can be used to enforce an addressing convention, e.g. ^/[a-z]+(/[a-z0-9]+)*$
+// Passing nil disables the check.
+func (conn *WSConn) SetAddressSchema(re *regexp.Regexp) {
+	conn.addressSchema = re
+}
+
+// SetExactMatch changes the behavior of the Serve method so that
+// messages will only be dispatched to methods whose addresses
+// match the message's address exactly.
+// This should provide some performance improvement.
+func (conn *WSConn) SetExactMatch(value bool) {
+	conn.exactMatch = value
+}
@@ -0,0 +1,154 @@
+package osc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestWSConn(t *testing.T) {
+	serverErrs := make(chan error, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := UpgradeWS(w, r)
+		if err != nil {
+			serverErrs <- err
+			return
+		}
+		defer func() { _ = conn.Close() }() // Best effort.
+
+		serverErrs <- conn.Serve(1, PatternMatching{
+			"/ping": Method(func(msg Message) error {
+				return conn.Send(Message{Address: "/pong", Arguments: msg.Arguments})
+			}),
+		})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := DialWS(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/osc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pongs := make(chan Message, 2)
+	clientErrs := make(chan error, 1)
+	go func() {
+		clientErrs <- client.Serve(1, PatternMatching{
+			"/pong": Method(func(msg Message) error {
+				pongs <- msg
+				return nil
+			}),
+		})
+	}()
+
+	// A message and a bundle, in separate binary messages.
+	if err := client.Send(Message{Address: "/ping", Arguments: Arguments{Int(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(NewBundle(Immediately, Message{Address: "/ping", Arguments: Arguments{String("two")}})); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []Message{
+		{Address: "/pong", Arguments: Arguments{Int(1)}},
+		{Address: "/pong", Arguments: Arguments{String("two")}},
+	} {
+		select {
+		case got := <-pongs:
+			if !expected.Equal(got) {
+				t.Fatalf("expected %+v, got %+v", expected, got)
+			}
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-clientErrs; err != nil {
+		t.Fatal(err)
+	}
+	// The server sees the close frame.
+	if err := <-serverErrs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWSConnReadFragmented(t *testing.T) {
+	a, b := net.Pipe()
+	defer func() { _ = a.Close() }() // Best effort.
+	defer func() { _ = b.Close() }() // Best effort.
+
+	var (
+		server = newWSConn(context.Background(), a, bufio.NewReader(a), false)
+		client = newWSConn(context.Background(), b, bufio.NewReader(b), true)
+		data   = Message{Address: "/foo", Arguments: Arguments{String("fragmented")}}.Bytes()
+	)
+	go func() {
+		// The message is split in two frames with a ping in between.
+		frames := []struct {
+			fin     byte
+			opcode  byte
+			payload []byte
+		}{
+			{fin: 0, opcode: wsBinary, payload: data[:5]},
+			{fin: 0x80, opcode: wsPing, payload: []byte("hi")},
+			{fin: 0x80, opcode: wsContinuation, payload: data[5:]},
+		}
+		for _, f := range frames {
+			frame := []byte{f.fin | f.opcode, 0x80 | byte(len(f.payload)), 0, 0, 0, 0}
+			frame = append(frame, f.payload...)
+			if _, err := b.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+	pong := make(chan []byte, 1)
+	go func() {
+		_, opcode, payload, err := client.readFrame(make([]byte, 16))
+		if err != nil || opcode != wsPong {
+			pong <- nil
+			return
+		}
+		pong <- payload
+	}()
+	buf := make([]byte, 64)
+	n, _, err := server.read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf[:n]) {
+		t.Fatalf("expected %q, got %q", data, buf[:n])
+	}
+	if expected, got := []byte("hi"), <-pong; !bytes.Equal(expected, got) {
+		t.Fatalf("expected pong %q, got %q", expected, got)
+	}
+}
+
+func TestUpgradeWSBadRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := UpgradeWS(w, r); errors.Cause(err) != ErrWebSocket {
+			t.Errorf("expected ErrWebSocket, got %+v", err)
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close() // Best effort.
+
+	if expected, got := http.StatusBadRequest, resp.StatusCode; expected != got {
+		t.Fatalf("expected %d, got %d", expected, got)
+	}
+}